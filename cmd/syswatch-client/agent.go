@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/clwg/syswatch/proto"
+	"github.com/clwg/syswatch/utils"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Reconnection backoff bounds. Each failed attempt doubles the delay, with
+// jitter, up to maxBackoff.
+const (
+	initialBackoff = time.Second
+	backoffFactor  = 2
+)
+
+// outputChunkSize is the most output a single message carries. Results with
+// more output send it ahead in chunks, well under gRPC's 4 MB message limit.
+const outputChunkSize = 1 << 20
+
+// agent keeps a stream to the server open, reconnecting whenever it drops,
+// and forwards buffered log lines over whichever stream is current.
+type agent struct {
+	client     pb.SysWatchClient
+	agentID    string
+	files      []string          // Files being tailed, reported on registration
+	labels     map[string]string // Operator-defined labels, reported on registration
+	buffer     *lineBuffer
+	maxBackoff time.Duration
+
+	heartbeatInterval time.Duration
+	missedHeartbeats  int // Server heartbeats that may be missed before reconnecting
+
+	maxCommandTimeout time.Duration        // Upper bound on the timeout the server may request
+	maxOutputBytes    int64                // Upper bound on the output per stream the server may ask to keep
+	killGrace         time.Duration        // How long a timed out or cancelled command has to exit before it is killed
+	limits            utils.ResourceLimits // Applied to every command, tightened by any the server requests
+	cgroupParent      string               // Cgroup v2 directory commands get cgroups of their own under, if any
+	policy            *policy              // Commands allowed to run, any command when nil
+	verifier          *commandVerifier     // Checks commands are signed by the operator key, any command when nil
+	commandUser       string               // User commands run as unless another is requested and allowed, the agent's own when empty
+	commandGroup      string               // Primary group of commandUser, its own when empty
+	running           sync.Map             // Command ID to the context.CancelFunc of each queued or running command
+	commandQueue      chan *queuedCommand
+	commandWorkers    int
+}
+
+// session is a single established stream and the connection ID the server
+// assigned to it.
+type session struct {
+	stream       pb.SysWatch_BidirectionalStreamPayloadClient
+	connectionID string
+	sendMu       sync.Mutex
+	lastHeard    atomic.Int64 // Unix nanoseconds of the last message from the server
+}
+
+// send serialises writes to the stream, which gRPC does not allow concurrently.
+func (s *session) send(msg *pb.RequestMessage) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.stream.Send(msg)
+}
+
+// run connects to the server and reconnects with exponential backoff and
+// jitter until ctx is cancelled.
+func (a *agent) run(ctx context.Context) {
+	a.startWorkers()
+
+	backoff := initialBackoff
+	for {
+		established, err := a.runSession(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if established {
+			backoff = initialBackoff
+		}
+
+		// Equal jitter, half the backoff plus a random share of the other
+		// half, keeps a fleet of agents from reconnecting in lockstep after a
+		// server restart.
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Printf("Connection to server lost (%v), reconnecting in %v", err, delay.Round(time.Millisecond))
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+
+		backoff *= backoffFactor
+		if backoff > a.maxBackoff {
+			backoff = a.maxBackoff
+		}
+	}
+}
+
+// runSession opens a stream, completes the handshake and then serves it until
+// either direction fails. It reports whether the handshake succeeded.
+func (a *agent) runSession(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	streamCtx := metadata.AppendToOutgoingContext(ctx, "syswatch-agent-id", a.agentID)
+	stream, err := a.client.BidirectionalStreamPayload(streamCtx)
+	if err != nil {
+		return false, fmt.Errorf("failed to create stream: %w", err)
+	}
+
+	// The server assigns our connection ID in the first message on the stream.
+	handshake, err := stream.Recv()
+	if err != nil {
+		return false, fmt.Errorf("failed to receive handshake: %w", err)
+	}
+	if handshake.GetHandshake().GetConnectionId() == "" {
+		return false, fmt.Errorf("unexpected handshake message from server: %v", handshake)
+	}
+	s := &session{stream: stream, connectionID: handshake.GetHandshake().GetConnectionId()}
+	s.lastHeard.Store(time.Now().UnixNano())
+	log.Printf("Server assigned connection ID %s", s.connectionID)
+
+	registration := &pb.RequestMessage{
+		ConnectionId: s.connectionID,
+		Body:         &pb.RequestMessage_Registration{Registration: buildRegistration(a.files, a.labels)},
+	}
+	if err := s.send(registration); err != nil {
+		return true, fmt.Errorf("failed to send registration: %w", err)
+	}
+
+	errCh := make(chan error, 3)
+	go func() { errCh <- a.receiveServerMessages(s) }()
+	go func() { errCh <- a.forwardLogs(ctx, s) }()
+	go func() { errCh <- a.sendHeartbeats(ctx, s) }()
+
+	return true, <-errCh
+}
+
+// forwardLogs sends buffered log lines until a send fails. The line that
+// failed stays buffered and is retried on the next session.
+func (a *agent) forwardLogs(ctx context.Context, s *session) error {
+	for {
+		line, seq, err := a.buffer.peek(ctx)
+		if err != nil {
+			return err
+		}
+
+		msg := &pb.RequestMessage{
+			ConnectionId: s.connectionID,
+			Body: &pb.RequestMessage_LogLine{
+				LogLine: &pb.LogLine{Source: line.Source, Line: line.Payload},
+			},
+		}
+		if err := s.send(msg); err != nil {
+			return fmt.Errorf("failed to send log message from %s: %w", line.Source, err)
+		}
+		a.buffer.pop(seq)
+	}
+}
+
+// sendHeartbeats sends a heartbeat every interval and gives up on the session
+// once the server has been silent for missedHeartbeats intervals.
+func (a *agent) sendHeartbeats(ctx context.Context, s *session) error {
+	ticker := time.NewTicker(a.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		silence := time.Since(time.Unix(0, s.lastHeard.Load()))
+		if silence > a.heartbeatInterval*time.Duration(a.missedHeartbeats) {
+			return fmt.Errorf("no message from server for %v", silence.Round(time.Second))
+		}
+
+		msg := &pb.RequestMessage{
+			ConnectionId: s.connectionID,
+			Body: &pb.RequestMessage_Heartbeat{
+				Heartbeat: &pb.Heartbeat{SentUnixNano: time.Now().UnixNano()},
+			},
+		}
+		if err := s.send(msg); err != nil {
+			return fmt.Errorf("failed to send heartbeat: %w", err)
+		}
+	}
+}
+
+func (a *agent) receiveServerMessages(s *session) error {
+	for {
+		response, err := s.stream.Recv()
+		if err != nil {
+			return fmt.Errorf("failed to receive response: %w", err)
+		}
+		s.lastHeard.Store(time.Now().UnixNano())
+
+		switch body := response.GetBody().(type) {
+		case *pb.ResponseMessage_Heartbeat:
+		case *pb.ResponseMessage_Command:
+			if a.verifier != nil {
+				if err := a.verifier.verify(body.Command); err != nil {
+					if err := a.denyCommand(s, body.Command, "signature check failed: "+err.Error()); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if err := a.queueCommand(s, body.Command); err != nil {
+				return err
+			}
+		case *pb.ResponseMessage_CancelCommand:
+			a.cancelCommand(body.CancelCommand)
+		default:
+			log.Printf("Ignoring unexpected message from server: %v", response)
+		}
+	}
+}
+
+// cancelCommand kills a running command, which then reports its result as
+// cancelled.
+func (a *agent) cancelCommand(cancel *pb.CancelCommand) {
+	value, ok := a.running.Load(cancel.GetCommandId())
+	if !ok {
+		log.Printf("Ignoring cancel of command %s, which is not running", cancel.GetCommandId())
+		return
+	}
+	log.Printf("Cancelling command %s at the request of %s", cancel.GetCommandId(), cancel.GetCancelledBy())
+	value.(context.CancelFunc)()
+}
+
+// runCommand executes a command and sends back its result. Cancelling ctx
+// kills the command.
+func (a *agent) runCommand(ctx context.Context, s *session, command *pb.Command) error {
+	commandID := command.GetCommandId()
+	log.Printf("Running command %s from %s for connection %s: %s", commandID, command.GetIssuedBy(), s.connectionID, command.GetCommand())
+
+	opts := a.commandOptions(command)
+	output := s.streamOutput(commandID)
+	if command.GetStream() {
+		opts.Output = output
+	}
+
+	argv := commandArgv(command)
+	if argv == nil {
+		shell, err := utils.ShellCommand(command.GetCommand())
+		if err != nil {
+			return a.sendResult(s, &pb.CommandResult{CommandId: commandID, ExitCode: -1, Error: err.Error()})
+		}
+		argv = shell
+	}
+
+	result := &pb.CommandResult{CommandId: commandID, ExitCode: -1}
+	executed, err := utils.ExecuteCommand(ctx, argv, opts)
+	if err != nil {
+		result.Error = err.Error()
+		log.Println("Error:", err)
+	} else {
+		result = newCommandResult(commandID, executed)
+		switch {
+		case command.GetStream():
+			result.OutputChunked = true
+		case len(result.Stdout)+len(result.Stderr) > outputChunkSize:
+			sendChunks(output, "stdout", result.Stdout)
+			sendChunks(output, "stderr", result.Stderr)
+			result.Stdout, result.Stderr, result.OutputChunked = nil, nil, true
+		}
+	}
+
+	return a.sendResult(s, result)
+}
+
+// sendChunks passes data to output in pieces of at most outputChunkSize.
+func sendChunks(output func(stream string, data []byte), stream string, data []byte) {
+	for len(data) > 0 {
+		n := min(len(data), outputChunkSize)
+		output(stream, data[:n])
+		data = data[n:]
+	}
+}
+
+func (a *agent) sendResult(s *session, result *pb.CommandResult) error {
+	responseMessage := &pb.RequestMessage{
+		ConnectionId: s.connectionID,
+		Body:         &pb.RequestMessage_CommandResult{CommandResult: result},
+	}
+	if err := s.send(responseMessage); err != nil {
+		return fmt.Errorf("failed to send response message: %w", err)
+	}
+	return nil
+}
+
+// commandArgv returns the argv a command is executed with directly, or nil
+// when it is to be run through the shell.
+func commandArgv(command *pb.Command) []string {
+	switch {
+	case command.GetProgram() != "":
+		return append([]string{command.GetProgram()}, command.GetArguments()...)
+	case command.GetShell():
+		return nil
+	default:
+		return strings.Fields(command.GetCommand())
+	}
+}
+
+// streamOutput returns a callback that sends each chunk of a command's output
+// to the server as it is produced.
+func (s *session) streamOutput(commandID string) func(stream string, data []byte) {
+	var mu sync.Mutex
+	var seq uint64
+	return func(stream string, data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		seq++
+		msg := &pb.RequestMessage{
+			ConnectionId: s.connectionID,
+			Body: &pb.RequestMessage_CommandOutput{CommandOutput: &pb.CommandOutput{
+				CommandId: commandID,
+				Stream:    stream,
+				Data:      data,
+				Seq:       seq,
+			}},
+		}
+		if err := s.send(msg); err != nil {
+			log.Printf("Failed to send output of command %s: %v", commandID, err)
+		}
+	}
+}
+
+// commandOptions applies the server's requested timeout, output limit,
+// resource limits, working directory, environment and user, or else the
+// agent's command user, capping the
+// timeout and output limit at the agent's maximums. Requested resource limits
+// only apply where they are stricter than the agent's own.
+func (a *agent) commandOptions(command *pb.Command) utils.CommandOptions {
+	timeout := min(utils.DefaultCommandTimeout, a.maxCommandTimeout)
+	if command.GetTimeout() != nil {
+		timeout = command.GetTimeout().AsDuration()
+	}
+	if timeout > a.maxCommandTimeout {
+		log.Printf("Capping timeout of command %s from %v to %v", command.GetCommandId(), timeout, a.maxCommandTimeout)
+		timeout = a.maxCommandTimeout
+	}
+
+	maxOutput := min(utils.DefaultMaxOutputBytes, a.maxOutputBytes)
+	if command.GetMaxOutputBytes() > 0 {
+		maxOutput = int64(min(command.GetMaxOutputBytes(), math.MaxInt64))
+	}
+	if maxOutput > a.maxOutputBytes {
+		log.Printf("Capping output of command %s from %d to %d bytes", command.GetCommandId(), maxOutput, a.maxOutputBytes)
+		maxOutput = a.maxOutputBytes
+	}
+
+	user, group := command.GetUser(), ""
+	if user == "" || user == a.commandUser {
+		user, group = a.commandUser, a.commandGroup
+	}
+
+	return utils.CommandOptions{
+		Timeout:        timeout,
+		Dir:            command.GetCwd(),
+		Env:            command.GetEnv(),
+		User:           user,
+		Group:          group,
+		MaxOutputBytes: maxOutput,
+		KillGrace:      a.killGrace,
+		Limits:         a.limits.Tighten(command.GetLimits().ResourceLimits()),
+		CgroupParent:   a.cgroupParent,
+	}
+}
+
+// newCommandResult converts an executed command into its wire representation.
+func newCommandResult(commandID string, executed *utils.CommandResult) *pb.CommandResult {
+	return &pb.CommandResult{
+		CommandId: commandID,
+		Stdout:    []byte(executed.Stdout),
+		Stderr:    []byte(executed.Stderr),
+		ExitCode:  int32(executed.ExitCode),
+		Signal:    executed.Signal,
+		StartTime: timestamppb.New(executed.StartTime),
+		EndTime:   timestamppb.New(executed.EndTime),
+		Duration:  durationpb.New(executed.Duration),
+		TimedOut:  executed.TimedOut,
+		Cancelled: executed.Cancelled,
+
+		Truncated:   executed.Truncated,
+		StdoutBytes: uint64(executed.StdoutBytes),
+		StderrBytes: uint64(executed.StderrBytes),
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// bufferedLine is a log line waiting to be sent to the server.
+type bufferedLine struct {
+	Source  string `json:"source"`
+	Payload string `json:"payload"`
+}
+
+// lineBuffer queues log lines while the server is unreachable. Up to maxLines
+// are held in memory; beyond that lines spill to spillPath when set, or the
+// oldest line is dropped. Once the spill file is full too, new lines are
+// dropped, since they cannot be delivered ahead of the spilled ones. Lines are
+// always delivered in the order they were pushed.
+type lineBuffer struct {
+	mu       sync.Mutex
+	lines    []bufferedLine
+	head     uint64 // Sequence number of lines[0], advanced whenever it is removed
+	maxLines int
+	notify   chan struct{}
+	dropped  int
+
+	spillPath     string
+	spillMaxBytes int64
+	spillFile     *os.File
+	spillWritten  int64 // bytes appended to the spill file
+	spillRead     int64 // bytes of the spill file already moved back into memory
+	spilled       int   // lines in the spill file not yet moved back into memory
+}
+
+func newLineBuffer(maxLines int, spillPath string, spillMaxBytes int64) *lineBuffer {
+	return &lineBuffer{
+		maxLines:      maxLines,
+		notify:        make(chan struct{}, 1),
+		spillPath:     spillPath,
+		spillMaxBytes: spillMaxBytes,
+	}
+}
+
+// push queues a line without blocking.
+func (b *lineBuffer) push(line bufferedLine) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.spilled == 0 && len(b.lines) < b.maxLines:
+		b.lines = append(b.lines, line)
+	case b.spillPath != "" && b.spillWritten < b.spillMaxBytes:
+		if err := b.spillLine(line); err != nil {
+			log.Printf("Failed to spill log line to %s: %v", b.spillPath, err)
+			b.countDropped()
+		}
+	case b.spilled == 0:
+		// Make room by dropping the oldest line held in memory.
+		b.lines = append(b.lines[1:], line)
+		b.head++
+		b.countDropped()
+	default:
+		// Older lines are waiting on disk, so the new line cannot jump ahead of them.
+		b.countDropped()
+	}
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// countDropped records a discarded line. Callers must hold b.mu.
+func (b *lineBuffer) countDropped() {
+	b.dropped++
+	if b.dropped == 1 || b.dropped%1000 == 0 {
+		log.Printf("Log buffer full, %d lines dropped so far", b.dropped)
+	}
+}
+
+// peek blocks until a line is available and returns it and its sequence
+// number without removing it, so a line whose send fails is retried on the
+// next connection.
+func (b *lineBuffer) peek(ctx context.Context) (bufferedLine, uint64, error) {
+	for {
+		b.mu.Lock()
+		if len(b.lines) == 0 && b.spilled > 0 {
+			if err := b.unspill(); err != nil {
+				log.Printf("Failed to read spilled log lines from %s: %v", b.spillPath, err)
+			}
+		}
+		if len(b.lines) > 0 {
+			line, seq := b.lines[0], b.head
+			b.mu.Unlock()
+			return line, seq, nil
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-b.notify:
+		case <-ctx.Done():
+			return bufferedLine{}, 0, ctx.Err()
+		}
+	}
+}
+
+// pop removes the line peek returned as seq, unless it has already been
+// dropped to make room for newer lines.
+func (b *lineBuffer) pop(seq uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.lines) > 0 && b.head == seq {
+		b.lines = b.lines[1:]
+		b.head++
+	}
+}
+
+// spillLine appends a line to the spill file. Callers must hold b.mu.
+func (b *lineBuffer) spillLine(line bufferedLine) error {
+	if b.spillFile == nil {
+		file, err := os.OpenFile(b.spillPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		b.spillFile = file
+		log.Printf("Log buffer full, spilling to %s", b.spillPath)
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	n, err := b.spillFile.WriteAt(append(data, '\n'), b.spillWritten)
+	b.spillWritten += int64(n)
+	if err != nil {
+		return err
+	}
+	b.spilled++
+	return nil
+}
+
+// unspill moves up to maxLines lines from the spill file back into memory and
+// truncates the file once it has been fully read. Callers must hold b.mu.
+func (b *lineBuffer) unspill() error {
+	reader := bufio.NewReader(io.NewSectionReader(b.spillFile, b.spillRead, b.spillWritten-b.spillRead))
+	for len(b.lines) < b.maxLines && b.spilled > 0 {
+		data, err := reader.ReadBytes('\n')
+		b.spillRead += int64(len(data))
+		if err != nil {
+			b.resetSpill()
+			return err
+		}
+		b.spilled--
+
+		var line bufferedLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			log.Printf("Skipping corrupt spilled log line: %v", err)
+			continue
+		}
+		b.lines = append(b.lines, line)
+	}
+
+	if b.spilled == 0 {
+		b.resetSpill()
+	}
+	return nil
+}
+
+// resetSpill empties the spill file. Callers must hold b.mu.
+func (b *lineBuffer) resetSpill() {
+	if b.spilled > 0 {
+		log.Printf("Discarding %d unreadable spilled log lines", b.spilled)
+	}
+	b.spilled, b.spillRead, b.spillWritten = 0, 0, 0
+	if err := b.spillFile.Truncate(0); err != nil {
+		log.Printf("Failed to truncate %s: %v", b.spillPath, err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLineBufferPopAfterDrop(t *testing.T) {
+	ctx := context.Background()
+	b := newLineBuffer(2, "", 0)
+	b.push(bufferedLine{Payload: "1"})
+	b.push(bufferedLine{Payload: "2"})
+
+	line, seq, err := b.peek(ctx)
+	if err != nil || line.Payload != "1" {
+		t.Fatalf("peek() = %v, %v", line, err)
+	}
+
+	// Line 1 is dropped to make room while it is being sent, so popping it
+	// must not remove line 2, which has not been sent yet.
+	b.push(bufferedLine{Payload: "3"})
+	b.pop(seq)
+
+	for _, want := range []string{"2", "3"} {
+		line, seq, err := b.peek(ctx)
+		if err != nil || line.Payload != want {
+			t.Fatalf("peek() = %v, %v, want %s", line, err, want)
+		}
+		b.pop(seq)
+	}
+}
+
+// drain returns the payloads of every line in b.
+func drain(t *testing.T, b *lineBuffer) []string {
+	t.Helper()
+	var payloads []string
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		line, seq, err := b.peek(ctx)
+		cancel()
+		if err != nil {
+			return payloads
+		}
+		payloads = append(payloads, line.Payload)
+		b.pop(seq)
+	}
+}
+
+func TestLineBufferFull(t *testing.T) {
+	// Each spilled line is 34 bytes, so the spill file holds two.
+	spillPath := filepath.Join(t.TempDir(), "spill.jsonl")
+	tests := []struct {
+		name      string
+		spillPath string
+		want      []string
+	}{
+		{"oldest dropped from memory", "", []string{"4", "5"}},
+		{"newest dropped once spilled", spillPath, []string{"1", "2", "3", "4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newLineBuffer(2, tt.spillPath, 68)
+			for i := 1; i <= 5; i++ {
+				b.push(bufferedLine{Source: "syslog", Payload: strconv.Itoa(i)})
+			}
+			if got := drain(t, b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buffer kept %v, want %v", got, tt.want)
+			}
+			if b.dropped != 5-len(tt.want) {
+				t.Errorf("dropped = %d, want %d", b.dropped, 5-len(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	pb "github.com/clwg/syswatch/proto"
+	"github.com/clwg/syswatch/utils"
+)
+
+// shellMetacharacters may not appear in a command allowed by a prefix rule,
+// so that an allowed prefix cannot be followed by a second command.
+const shellMetacharacters = ";&|<>()$`\\\"'\n*?[]{}~#!"
+
+// policy decides which commands the agent will run. Anything not allowed by
+// a rule or template is denied.
+type policy struct {
+	// AllowShell lets rules allow commands run through the shell. Shell
+	// templates defined by the policy are always allowed.
+	AllowShell bool                       `json:"allow_shell"`
+	Rules      []*policyRule              `json:"rules"`
+	Templates  map[string]*utils.Template `json:"templates"`
+
+	// AllowedUsers are the users the server may ask a command to run as. A
+	// command allowed by a template with a user always runs as that user.
+	AllowedUsers []string `json:"allowed_users"`
+}
+
+// policyRule allows commands that are exactly a string, start with a prefix,
+// or fully match a regular expression. Each rule sets one of the three.
+type policyRule struct {
+	Exact  string `json:"exact,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Regex  string `json:"regex,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// loadPolicy reads and validates the policy in filename.
+func loadPolicy(filename string) (*policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var p policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	for i, rule := range p.Rules {
+		set := 0
+		for _, value := range []string{rule.Exact, rule.Prefix, rule.Regex} {
+			if value != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("%s: rule %d must set exactly one of exact, prefix or regex", filename, i+1)
+		}
+		if rule.Regex != "" {
+			regex, err := regexp.Compile("^(?:" + rule.Regex + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("%s: rule %d: %v", filename, i+1, err)
+			}
+			rule.regex = regex
+		}
+	}
+	for name, template := range p.Templates {
+		if err := template.Compile(); err != nil {
+			return nil, fmt.Errorf("%s: template %s: %v", filename, name, err)
+		}
+	}
+	return &p, nil
+}
+
+// authorize reports which rule or template allows command, or why it is
+// denied. A command rendered from a template the policy also defines is
+// rendered again from the policy's own definition, which is what runs, and a
+// command allowed by a template with a user is set to run as that user.
+func (p *policy) authorize(command *pb.Command) (string, error) {
+	allowed, template, err := p.allow(command)
+	if err != nil {
+		return "", err
+	}
+
+	if template != nil && template.User != "" {
+		if command.GetUser() != "" && command.GetUser() != template.User {
+			log.Printf("Running command %s as %s, the user of the policy %s, rather than %s", command.GetCommandId(), template.User, allowed, command.GetUser())
+		}
+		command.User = template.User
+	} else if command.GetUser() != "" && !slices.Contains(p.AllowedUsers, command.GetUser()) {
+		return "", fmt.Errorf("user %s is not allowed by the agent policy", command.GetUser())
+	}
+	return allowed, nil
+}
+
+// allow reports which rule or template allows command, along with the
+// template if it was one.
+func (p *policy) allow(command *pb.Command) (string, *utils.Template, error) {
+	if name := command.GetTemplate(); name != "" {
+		if template, ok := p.Templates[name]; ok {
+			rendered, argv, err := template.Render(command.GetArgs())
+			if err != nil {
+				return "", nil, fmt.Errorf("template %s: %v", name, err)
+			}
+			if rendered != command.GetCommand() {
+				log.Printf("Running command %s as rendered by the policy template %s: %s", command.GetCommandId(), name, rendered)
+			}
+			command.Command, command.Shell = rendered, argv == nil
+			command.Program, command.Arguments = "", nil
+			if argv != nil {
+				command.Program, command.Arguments = argv[0], argv[1:]
+			}
+			return "template " + name, template, nil
+		}
+	}
+
+	argv := commandArgv(command)
+	if argv == nil {
+		return p.allowShell(command.GetCommand())
+	}
+	return p.allowArgv(argv)
+}
+
+// allowShell reports which rule or template allows a command run through the
+// shell, or why it is denied.
+func (p *policy) allowShell(command string) (string, *utils.Template, error) {
+	for name, template := range p.Templates {
+		if _, err := template.Match(command); err == nil {
+			return "template " + name, template, nil
+		}
+	}
+	if !p.AllowShell {
+		return "", nil, fmt.Errorf("shell commands are not allowed by the agent policy")
+	}
+	allowed, err := p.matchRules(command)
+	return allowed, nil, err
+}
+
+// allowArgv reports which rule or template allows a command executed
+// directly, or why it is denied. Rules match its shell-quoted form.
+func (p *policy) allowArgv(argv []string) (string, *utils.Template, error) {
+	for name, template := range p.Templates {
+		if _, err := template.MatchArgv(argv); err == nil {
+			return "template " + name, template, nil
+		}
+	}
+	allowed, err := p.matchRules(utils.JoinArgv(argv))
+	return allowed, nil, err
+}
+
+func (p *policy) matchRules(command string) (string, error) {
+	for i, rule := range p.Rules {
+		switch {
+		case rule.Exact != "" && command == rule.Exact:
+			return fmt.Sprintf("rule %d (exact)", i+1), nil
+		case rule.Prefix != "" && strings.HasPrefix(command, rule.Prefix) && !strings.ContainsAny(command, shellMetacharacters):
+			return fmt.Sprintf("rule %d (prefix)", i+1), nil
+		case rule.regex != nil && rule.regex.MatchString(command):
+			return fmt.Sprintf("rule %d (regex)", i+1), nil
+		}
+	}
+	return "", fmt.Errorf("command is not allowed by the agent policy")
+}
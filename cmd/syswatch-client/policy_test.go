@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/clwg/syswatch/proto"
+)
+
+const testPolicy = `{
+  "allow_shell": %s,
+  "allowed_users": ["backup"],
+  "rules": [
+    {"exact": "uptime"},
+    {"prefix": "df "},
+    {"regex": "systemctl status [A-Za-z0-9@._][A-Za-z0-9@._-]*"}
+  ],
+  "templates": {
+    "disk_usage": {"argv": ["du", "-sh", "{path}"], "params": {"path": {"type": "path"}}},
+    "tail_log": {
+      "argv": ["tail", "-n", "{lines}", "/var/log/syslog"],
+      "user": "root",
+      "params": {"lines": {"type": "int", "min": 1, "max": 1000}}
+    },
+    "top_memory": {
+      "command": "ps aux --sort=-%%mem | head -n {count}",
+      "params": {"count": {"type": "int", "min": 1, "max": 100}}
+    }
+  }
+}`
+
+func writePolicy(t *testing.T, allowShell bool) *policy {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "policy.json")
+	shell := "false"
+	if allowShell {
+		shell = "true"
+	}
+	if err := os.WriteFile(filename, []byte(fmt.Sprintf(testPolicy, shell)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadPolicy(filename)
+	if err != nil {
+		t.Fatalf("loadPolicy: %v", err)
+	}
+	return p
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowShell bool
+		command    *pb.Command
+		allowed    bool
+		wantUser   string
+	}{
+		{"exact", false, &pb.Command{Command: "uptime"}, true, ""},
+		{"exact with extra argument", false, &pb.Command{Command: "uptime -p"}, false, ""},
+		{"prefix", false, &pb.Command{Command: "df -h"}, true, ""},
+		{"prefix program", false, &pb.Command{Program: "df", Arguments: []string{"-h", "/"}}, true, ""},
+		{"prefix with semicolon", false, &pb.Command{Command: "df -h; rm -rf /"}, false, ""},
+		{"prefix argument with metacharacters", false, &pb.Command{Program: "df", Arguments: []string{"-h", "$(id)"}}, false, ""},
+		{"prefix shell and", true, &pb.Command{Command: "df -h && id", Shell: true}, false, ""},
+		{"prefix shell substitution", true, &pb.Command{Command: "df `id`", Shell: true}, false, ""},
+		{"prefix shell pipe", true, &pb.Command{Command: "df -h | mail x", Shell: true}, false, ""},
+		{"prefix shell newline", true, &pb.Command{Command: "df -h\nid", Shell: true}, false, ""},
+		{"regex", false, &pb.Command{Command: "systemctl status ssh.service"}, true, ""},
+		{"regex option injection", false, &pb.Command{Command: "systemctl status -Hroot@attacker.example"}, false, ""},
+		{"shell without allow_shell", false, &pb.Command{Command: "uptime", Shell: true}, false, ""},
+		{"shell with allow_shell", true, &pb.Command{Command: "uptime", Shell: true}, true, ""},
+		{"shell template without allow_shell", false, &pb.Command{Command: "ps aux --sort=-%mem | head -n 5", Shell: true}, true, ""},
+		{"shell template with bad argument", false, &pb.Command{Command: "ps aux --sort=-%mem | head -n 5; id", Shell: true}, false, ""},
+		{"argv template", false, &pb.Command{Program: "du", Arguments: []string{"-sh", "/var/log"}}, true, ""},
+		{"argv template bad path", false, &pb.Command{Program: "du", Arguments: []string{"-sh", "/var/../etc"}}, false, ""},
+		{"not allowed", true, &pb.Command{Command: "rm -rf /"}, false, ""},
+		{"allowed user", false, &pb.Command{Command: "uptime", User: "backup"}, true, "backup"},
+		{"user not allowed", false, &pb.Command{Command: "uptime", User: "root"}, false, ""},
+		{"template user", false, &pb.Command{Program: "tail", Arguments: []string{"-n", "10", "/var/log/syslog"}}, true, "root"},
+		{"template user overrides request", false, &pb.Command{Program: "tail", Arguments: []string{"-n", "10", "/var/log/syslog"}, User: "backup"}, true, "root"},
+		{"named template user", false, &pb.Command{Template: "tail_log", Args: map[string]string{"lines": "10"}, Command: "tail -n 10 /etc/shadow", User: "nobody"}, true, "root"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := writePolicy(t, tt.allowShell)
+			_, err := p.authorize(tt.command)
+			if (err == nil) != tt.allowed {
+				t.Fatalf("authorize() error = %v, want allowed %v", err, tt.allowed)
+			}
+			if tt.allowed && tt.command.GetUser() != tt.wantUser {
+				t.Errorf("user = %q, want %q", tt.command.GetUser(), tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestPolicyAuthorizeRendersNamedTemplate(t *testing.T) {
+	p := writePolicy(t, false)
+
+	// The server's rendering is replaced by the policy's own.
+	command := &pb.Command{
+		Template: "disk_usage",
+		Args:     map[string]string{"path": "/var/log"},
+		Command:  "rm -rf /var/log",
+		Shell:    true,
+	}
+	if _, err := p.authorize(command); err != nil {
+		t.Fatalf("authorize() = %v", err)
+	}
+	if command.GetShell() || command.GetProgram() != "du" || len(command.GetArguments()) != 2 || command.GetArguments()[1] != "/var/log" {
+		t.Errorf("command = %v, want du -sh /var/log executed directly", command)
+	}
+
+	command = &pb.Command{Template: "disk_usage", Args: map[string]string{"path": "/var/log; id"}}
+	if _, err := p.authorize(command); err == nil {
+		t.Error("authorize() of an invalid template argument succeeded")
+	}
+}
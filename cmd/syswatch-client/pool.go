@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	pb "github.com/clwg/syswatch/proto"
+)
+
+// rejectBusy is the reason given when a command arrives with every worker
+// occupied and the queue full.
+const rejectBusy = "busy"
+
+// queuedCommand is a command waiting for a worker.
+type queuedCommand struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	session *session
+	command *pb.Command
+}
+
+// startWorkers starts the workers that run queued commands.
+func (a *agent) startWorkers() {
+	for i := 0; i < a.commandWorkers; i++ {
+		go a.runWorker()
+	}
+}
+
+func (a *agent) runWorker() {
+	for queued := range a.commandQueue {
+		commandID := queued.command.GetCommandId()
+		var err error
+		if queued.ctx.Err() != nil {
+			err = a.sendResult(queued.session, &pb.CommandResult{
+				CommandId: commandID,
+				ExitCode:  -1,
+				Error:     "cancelled before it started",
+				Cancelled: true,
+			})
+		} else {
+			err = a.runCommand(queued.ctx, queued.session, queued.command)
+		}
+		if err != nil {
+			log.Printf("Command %s: %v", commandID, err)
+		}
+
+		queued.cancel()
+		a.running.Delete(commandID)
+	}
+}
+
+// queueCommand hands a command to the worker pool without blocking the
+// receive loop, acknowledging it once queued or rejecting it as busy.
+func (a *agent) queueCommand(s *session, command *pb.Command) error {
+	commandID := command.GetCommandId()
+	if a.policy != nil {
+		rule, err := a.policy.authorize(command)
+		if err != nil {
+			return a.denyCommand(s, command, err.Error())
+		}
+		log.Printf("Command %s is allowed by %s", commandID, rule)
+	} else if a.commandUser != "" && command.GetUser() != "" && command.GetUser() != a.commandUser {
+		return a.denyCommand(s, command, "running commands as another user requires an agent policy allowing it")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := &queuedCommand{ctx: ctx, cancel: cancel, session: s, command: command}
+	a.running.Store(commandID, cancel)
+
+	select {
+	case a.commandQueue <- queued:
+	default:
+		cancel()
+		a.running.Delete(commandID)
+		log.Printf("Rejecting command %s, all %d workers are busy and the queue is full", commandID, a.commandWorkers)
+		reject := &pb.RequestMessage{
+			ConnectionId: s.connectionID,
+			Body: &pb.RequestMessage_CommandRejected{
+				CommandRejected: &pb.CommandRejected{CommandId: commandID, Reason: rejectBusy},
+			},
+		}
+		if err := s.send(reject); err != nil {
+			return fmt.Errorf("failed to reject command: %w", err)
+		}
+		return nil
+	}
+
+	if a.verifier != nil {
+		if err := a.verifier.remember(command); err != nil {
+			log.Printf("Failed to record command %s as run, it could be replayed after a restart: %v", commandID, err)
+		}
+	}
+
+	ack := &pb.RequestMessage{
+		ConnectionId: s.connectionID,
+		Body:         &pb.RequestMessage_Ack{Ack: &pb.Ack{CommandId: commandID}},
+	}
+	if err := s.send(ack); err != nil {
+		return fmt.Errorf("failed to acknowledge command: %w", err)
+	}
+	return nil
+}
+
+// denyCommand tells the server the agent policy forbids a command.
+func (a *agent) denyCommand(s *session, command *pb.Command, reason string) error {
+	log.Printf("Denying command %s from %s: %s: %s", command.GetCommandId(), command.GetIssuedBy(), reason, command.GetCommand())
+	denial := &pb.RequestMessage{
+		ConnectionId: s.connectionID,
+		Body: &pb.RequestMessage_PolicyDenial{
+			PolicyDenial: &pb.PolicyDenial{CommandId: command.GetCommandId(), Reason: reason},
+		},
+	}
+	if err := s.send(denial); err != nil {
+		return fmt.Errorf("failed to send policy denial: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	pb "github.com/clwg/syswatch/proto"
+)
+
+// fakeStream is the agent side of a stream whose sends are recorded.
+type fakeStream struct {
+	pb.SysWatch_BidirectionalStreamPayloadClient
+
+	mu   sync.Mutex
+	sent []*pb.RequestMessage
+}
+
+func (f *fakeStream) Send(msg *pb.RequestMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestQueueCommandRejectsWhenBusy(t *testing.T) {
+	stream := &fakeStream{}
+	s := &session{stream: stream, connectionID: "conn"}
+	// No workers are running, so the queue fills after one command.
+	a := &agent{commandQueue: make(chan *queuedCommand, 1), commandWorkers: 1}
+
+	for _, commandID := range []string{"queued", "rejected"} {
+		if err := a.queueCommand(s, &pb.Command{CommandId: commandID, Command: "uptime"}); err != nil {
+			t.Fatalf("queueCommand(%s) = %v", commandID, err)
+		}
+	}
+
+	if len(stream.sent) != 2 {
+		t.Fatalf("sent %d messages, want an ack and a rejection", len(stream.sent))
+	}
+	if ack := stream.sent[0].GetAck(); ack.GetCommandId() != "queued" {
+		t.Errorf("first message = %v, want an ack of the queued command", stream.sent[0])
+	}
+	if reject := stream.sent[1].GetCommandRejected(); reject.GetCommandId() != "rejected" || reject.GetReason() != rejectBusy {
+		t.Errorf("second message = %v, want the rejected command rejected as busy", stream.sent[1])
+	}
+	for _, msg := range stream.sent {
+		if msg.GetConnectionId() != "conn" {
+			t.Errorf("message sent for connection %q, want conn", msg.GetConnectionId())
+		}
+	}
+	if _, ok := a.running.Load("queued"); !ok {
+		t.Error("queued command is not tracked")
+	}
+	if _, ok := a.running.Load("rejected"); ok {
+		t.Error("rejected command is still tracked")
+	}
+
+	// A command cancelled while queued is reported without running.
+	cancel, _ := a.running.Load("queued")
+	cancel.(context.CancelFunc)()
+	close(a.commandQueue)
+	a.runWorker()
+
+	if len(stream.sent) != 3 {
+		t.Fatalf("sent %d messages, want a result for the cancelled command", len(stream.sent))
+	}
+	if result := stream.sent[2].GetCommandResult(); result.GetCommandId() != "queued" || !result.GetCancelled() {
+		t.Errorf("result = %v, want the queued command cancelled", stream.sent[2])
+	}
+	if _, ok := a.running.Load("queued"); ok {
+		t.Error("finished command is still tracked")
+	}
+}
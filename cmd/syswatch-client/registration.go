@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"runtime"
+
+	pb "github.com/clwg/syswatch/proto"
+	"golang.org/x/sys/unix"
+)
+
+// agentVersion is reported to the server when the agent registers.
+const agentVersion = "0.1.0-alpha"
+
+// buildRegistration collects the host metadata the agent reports after each
+// handshake.
+func buildRegistration(files []string, labels map[string]string) *pb.Registration {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Printf("Failed to read hostname: %v", err)
+	}
+
+	return &pb.Registration{
+		Hostname:      hostname,
+		Os:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		KernelVersion: kernelVersion(),
+		AgentVersion:  agentVersion,
+		IpAddresses:   ipAddresses(),
+		Files:         files,
+		Labels:        labels,
+	}
+}
+
+func kernelVersion() string {
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		log.Printf("Failed to read kernel version: %v", err)
+		return ""
+	}
+	return unix.ByteSliceToString(uname.Release[:])
+}
+
+// ipAddresses lists the host's non-loopback interface addresses.
+func ipAddresses() []string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		log.Printf("Failed to read interface addresses: %v", err)
+		return nil
+	}
+
+	var ips []string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() {
+			continue
+		}
+		ips = append(ips, ipNet.IP.String())
+	}
+	return ips
+}
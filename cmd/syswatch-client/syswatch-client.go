@@ -3,22 +3,27 @@ package main
 import (
 	"bufio"
 	"context"
-	"encoding/base64"
+	cryptotls "crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"sync"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/clwg/syswatch/data"
 	pb "github.com/clwg/syswatch/proto"
 	"github.com/clwg/syswatch/utils"
+	"github.com/google/uuid"
 	"github.com/hpcloud/tail"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 	"golang.org/x/sys/unix"
 
 )
@@ -26,24 +31,67 @@ import (
 var (
 	tls                = flag.Bool("tls", false, "Connection uses TLS if true, else plain TCP")
 	caFile             = flag.String("ca_file", "", "The file containing the CA root cert file")
+	certFile           = flag.String("cert_file", "", "The agent certificate presented to the server for mutual TLS")
+	keyFile            = flag.String("key_file", "", "The agent key for mutual TLS")
 	serverAddr         = flag.String("addr", "localhost:51001", "The server address in the format of host:port")
 	serverHostOverride = flag.String("server_host_override", "x.test.example.com", "The server name used to verify the hostname returned by the TLS handshake")
 	filelist           = flag.String("filelist", "path/to/your/filelist.txt", "File containing the list of files to tail")
+	stateFile          = flag.String("state_file", "syswatch-agent.json", "File the persistent agent ID is kept in")
+	bufferLines        = flag.Int("buffer_lines", 10000, "The maximum number of log lines held in memory while disconnected")
+	spillFile          = flag.String("buffer_spill_file", "", "File log lines spill to once the memory buffer is full, disabled if empty")
+	spillMaxBytes      = flag.Int64("buffer_spill_max_bytes", 64<<20, "The maximum size of the spill file")
+	maxBackoff         = flag.Duration("reconnect_max_backoff", time.Minute, "The maximum delay between reconnection attempts")
+	labels             = flag.String("labels", "", "Comma separated key=value labels reported to the server, e.g. env=prod,role=db")
+	heartbeat          = flag.Duration("heartbeat_interval", 15*time.Second, "How often a heartbeat is sent to the server")
+	missedBeats        = flag.Int("missed_heartbeats", 3, "The number of server heartbeats that may be missed before reconnecting")
+	maxCmdTimeout      = flag.Duration("max_command_timeout", 5*time.Minute, "The longest the server may ask a command to run for")
+	maxOutputBytes     = flag.Int64("max_output_bytes", 16<<20, "The most output per stream the server may ask a command to keep")
+	killGrace          = flag.Duration("kill_grace", utils.DefaultKillGrace, "How long a timed out or cancelled command has after SIGTERM before it is killed")
+	maxConcurrent      = flag.Int("max_concurrent_commands", 4, "The number of commands that may run at once")
+	queueDepth         = flag.Int("command_queue_depth", 16, "The number of commands that may wait for a free worker before further commands are rejected as busy")
+	policyFile         = flag.String("policy_file", "", "JSON file of the commands the agent may run, any command if empty")
+	commandPubkey      = flag.String("command_pubkey", "", "PEM file of the Ed25519 public key every command must be signed with, unsigned commands are run if empty")
+	commandUser        = flag.String("command_user", "", "Unprivileged user, a name or uid, commands run as rather than the agent's own")
+	commandGroup       = flag.String("command_group", "", "Group, a name or gid, commands run with rather than the primary group of -command_user")
+	limitCPUTime       = flag.Duration("limit_cpu_time", 0, "The CPU time a command may use, unlimited if zero")
+	limitMemory        = flag.Int64("limit_memory_bytes", 0, "The memory a command may use, unlimited if zero")
+	limitProcesses     = flag.Int("limit_processes", 0, "The number of processes a command may run, unlimited if zero; requires -cgroup_parent")
+	nice               = flag.Int("nice", 0, "The nice level from 1 to 19 commands run at, unchanged if zero")
+	ioClass            = flag.String("ionice_class", "", "The I/O scheduling class commands run in, best-effort or idle, unchanged if empty")
+	ioPriority         = flag.Int("ionice_priority", 4, "The I/O priority from 0 to 7 of commands in the best-effort class")
+	cgroupParent       = flag.String("cgroup_parent", "", "Cgroup v2 directory, with the memory and pids controllers enabled, each command gets a cgroup under; memory is limited with an rlimit if empty")
 )
 
+// keepaliveParams pings the server on idle connections; Time must not be
+// below the server's keepalive enforcement MinTime.
+var keepaliveParams = keepalive.ClientParameters{
+	Time:                30 * time.Second,
+	Timeout:             10 * time.Second,
+	PermitWithoutStream: true,
+}
+
+// agentState is persisted between runs so the agent keeps its identity across restarts.
+type agentState struct {
+	AgentID string `json:"agent_id"`
+}
+
 func main() {
+	// Commands with resource limits are started through a re-execution of
+	// the agent, which must not go on to run as one.
+	utils.RunLimitsHelper()
+
 	flag.Parse()
 	// Set up a connection to the server.
-	var opts []grpc.DialOption
+	opts := []grpc.DialOption{grpc.WithKeepaliveParams(keepaliveParams)}
 	if *tls {
 		if *caFile == "" {
 			*caFile = data.Path("data/x509/ca_cert.pem")
 		}
-		creds, err := credentials.NewClientTLSFromFile(*caFile, *serverHostOverride)
+		tlsConfig, err := clientTLSConfig(*caFile, *certFile, *keyFile, *serverHostOverride)
 		if err != nil {
 			log.Fatalf("Failed to create TLS credentials: %v", err)
 		}
-		opts = append(opts, grpc.WithTransportCredentials(creds))
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 		log.Println("TLS connection established")
 	} else {
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
@@ -57,18 +105,93 @@ func main() {
 	defer conn.Close()
 
 	client := pb.NewSysWatchClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
 
-	connUuid, err := client.GenerateUUID(ctx, &pb.Empty{})
+	state, err := loadAgentState(*stateFile)
 	if err != nil {
-		log.Fatalf("Could not generate UUID: %v", err)
+		log.Fatalf("Failed to load agent state: %v", err)
+	}
+	if *tls && *certFile != "" {
+		// The server requires the agent ID to match the certificate.
+		state.AgentID, err = certificateIdentity(*certFile)
+		if err != nil {
+			log.Fatalf("Failed to read agent certificate: %v", err)
+		}
+	}
+	log.Printf("Agent ID %s", state.AgentID)
+
+	if *bufferLines < 1 {
+		log.Fatalf("-buffer_lines must be at least 1")
+	}
+	if *maxBackoff <= 0 {
+		log.Fatalf("-reconnect_max_backoff must be positive")
+	}
+	if *heartbeat <= 0 || *missedBeats < 1 {
+		log.Fatalf("-heartbeat_interval and -missed_heartbeats must be positive")
+	}
+	if *maxCmdTimeout <= 0 || *maxOutputBytes <= 0 || *killGrace <= 0 {
+		log.Fatalf("-max_command_timeout, -max_output_bytes and -kill_grace must be positive")
+	}
+	if *maxConcurrent < 1 || *queueDepth < 0 {
+		log.Fatalf("-max_concurrent_commands must be at least 1 and -command_queue_depth must not be negative")
+	}
+	limits := utils.ResourceLimits{
+		CPUTime:      *limitCPUTime,
+		MemoryBytes:  *limitMemory,
+		MaxProcesses: *limitProcesses,
+		Nice:         *nice,
+		IOClass:      *ioClass,
+	}
+	if *ioClass == utils.IOClassBestEffort {
+		limits.IOPriority = *ioPriority
+	}
+	if err := limits.Validate(); err != nil {
+		log.Fatalf("Invalid command resource limits: %v", err)
+	}
+	var verifier *commandVerifier
+	if *commandPubkey != "" {
+		key, err := utils.LoadPublicKey(*commandPubkey)
+		if err != nil {
+			log.Fatalf("Failed to load command public key: %v", err)
+		}
+		seenFile := strings.TrimSuffix(*stateFile, filepath.Ext(*stateFile)) + ".seen.json"
+		verifier, err = newCommandVerifier(key, state.AgentID, seenFile)
+		if err != nil {
+			log.Fatalf("Failed to load signed commands already run: %v", err)
+		}
+		log.Printf("Only commands signed with the key in %s will run", *commandPubkey)
+	}
+	if *commandGroup != "" && *commandUser == "" {
+		log.Fatalf("-command_group requires -command_user")
+	}
+	if *commandUser != "" {
+		if err := utils.CheckUnprivileged(*commandUser, *commandGroup); err != nil {
+			log.Fatalf("Cannot run commands as -command_user: %v", err)
+		}
+		log.Printf("Commands run as %s", *commandUser)
+	}
+	if *cgroupParent != "" {
+		if err := utils.CheckCgroupParent(*cgroupParent); err != nil {
+			log.Fatalf("Cannot use -cgroup_parent: %v", err)
+		}
+	} else if *limitProcesses > 0 {
+		log.Fatalf("-limit_processes requires -cgroup_parent")
 	}
-	connectionID := connUuid.GetUuid()
+	buffer := newLineBuffer(*bufferLines, *spillFile, *spillMaxBytes)
 
-	stream, err := client.BidirectionalStreamPayload(context.Background())
+	var commandPolicy *policy
+	if *policyFile != "" {
+		commandPolicy, err = loadPolicy(*policyFile)
+		if err != nil {
+			log.Fatalf("Failed to load policy: %v", err)
+		}
+		log.Printf("Loaded command policy from %s", *policyFile)
+	} else {
+		log.Println("No policy file configured, the agent will run any command it is sent")
+	}
+
+	agentLabels, err := utils.ParseLabels(*labels)
 	if err != nil {
-		log.Fatalf("Failed to create stream: %v", err)
+		log.Fatalf("Failed to parse labels: %v", err)
 	}
 
 	file, err := os.Open(*filelist)
@@ -78,17 +201,14 @@ func main() {
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
-	var wg sync.WaitGroup
+	var tailed []string
 
 	for scanner.Scan() {
 		filename := scanner.Text()
 		if checkFilePermissions(filename) {
 			log.Printf("File %s stream enabled", filename)
-			wg.Add(1)
-			go func(filename string) {
-				defer wg.Done()
-				tailFileAndSendLogs(filename, connectionID, stream)
-			}(filename)
+			tailed = append(tailed, filename)
+			go tailFile(filename, buffer)
 		} else {
 			log.Printf("File %s cannot be read due to insufficient permissions", filename)
 		}
@@ -98,54 +218,36 @@ func main() {
 		log.Fatalf("Error reading filelist: %v", err)
 	}
 
-	go receiveServerMessages(stream, connectionID)
-
-	// Block main from exiting
-	wg.Wait()
-}
-
-func receiveServerMessages(stream pb.SysWatch_BidirectionalStreamPayloadClient, connectionID string) {
-	for {
-		response, err := stream.Recv()
-		if err != nil {
-			log.Fatalf("Failed to receive response: %v", err)
-		}
-
-		log.Printf("Received server message for connection %s: %s", connectionID, response.GetPayload())
-
-		result, err := utils.ExecuteCommand(response.GetPayload())
-		responsePayload := ""
-		if err != nil {
-			responsePayload = fmt.Sprintf("Error: %s", err)
-			log.Println("Error:", err)
-		} else {
-			encodedResult := base64.StdEncoding.EncodeToString([]byte(result))
-			responsePayload = encodedResult
-		}
-
-		streamResponse, err := json.Marshal(map[string]string{
-			"connection_id":    connectionID,
-			"response_payload": responsePayload,
-			"source":           "direct",
-		})
-		if err != nil {
-			log.Fatalf("Failed to marshal JSON: %v", err)
-		}
+	a := &agent{
+		client:     client,
+		agentID:    state.AgentID,
+		files:      tailed,
+		labels:     agentLabels,
+		buffer:     buffer,
+		maxBackoff: *maxBackoff,
 
-		responseMessage := &pb.RequestMessage{
-			Payload:      string(streamResponse),
-			ConnectionId: connectionID,
-			Source:       "direct",
-		}
+		heartbeatInterval: *heartbeat,
+		missedHeartbeats:  *missedBeats,
 
-		if err := stream.Send(responseMessage); err != nil {
-			log.Printf("Failed to send response message: %v", err)
-			return
-		}
+		maxCommandTimeout: *maxCmdTimeout,
+		maxOutputBytes:    *maxOutputBytes,
+		killGrace:         *killGrace,
+		limits:            limits,
+		cgroupParent:      *cgroupParent,
+		policy:            commandPolicy,
+		verifier:          verifier,
+		commandUser:       *commandUser,
+		commandGroup:      *commandGroup,
+		commandQueue:      make(chan *queuedCommand, *queueDepth),
+		commandWorkers:    *maxConcurrent,
 	}
+	a.run(context.Background())
 }
 
-func tailFileAndSendLogs(filename, connectionID string, stream pb.SysWatch_BidirectionalStreamPayloadClient) {
+// tailFile follows filename and queues each new line for the server. Tailing
+// is independent of the server connection so nothing is missed while
+// reconnecting.
+func tailFile(filename string, buffer *lineBuffer) {
 	t, err := tail.TailFile(filename, tail.Config{Follow: true, ReOpen: true, Poll: true})
 	if err != nil {
 		log.Fatalf("Failed to start tailing file %s: %v", filename, err)
@@ -156,17 +258,83 @@ func tailFileAndSendLogs(filename, connectionID string, stream pb.SysWatch_Bidir
 			log.Printf("Error reading line from %s: %v", filename, line.Err)
 			continue
 		}
-		jsonMessage := &pb.RequestMessage{
-			Payload:      line.Text,
-			ConnectionId: connectionID,
-			Source:       filename,
+		buffer.push(bufferedLine{Source: filename, Payload: line.Text})
+	}
+}
+
+// loadAgentState reads the agent state from filename, creating it with a new
+// agent ID on first start.
+func loadAgentState(filename string) (*agentState, error) {
+	var state agentState
+	data, err := os.ReadFile(filename)
+	if err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
 		}
-		if err := stream.Send(jsonMessage); err != nil {
-			log.Printf("Failed to send log message from %s: %v", filename, err)
-			return
+		if state.AgentID != "" {
+			return &state, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	state.AgentID = uuid.New().String()
+	data, err = json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filename, data, 0600); err != nil {
+		return nil, err
+	}
+	log.Printf("Created agent state file %s", filename)
+	return &state, nil
+}
+
+// clientTLSConfig trusts the server CA in caFile and, when certFile and keyFile
+// are set, presents the agent certificate for mutual TLS.
+func clientTLSConfig(caFile, certFile, keyFile, serverName string) (*cryptotls.Config, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	config := &cryptotls.Config{RootCAs: pool, ServerName: serverName}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := cryptotls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
 		}
-		//log.Printf("Sent log message from %s: %s", filename, line.Text)
+		config.Certificates = []cryptotls.Certificate{cert}
+		log.Println("Presenting agent certificate for mutual TLS")
+	}
+
+	return config, nil
+}
+
+// certificateIdentity returns the identity the server derives from the
+// agent certificate in certFile: its subject common name, or the whole
+// subject when it has none.
+func certificateIdentity(certFile string) (string, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", fmt.Errorf("no certificate found in %s", certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", certFile, err)
+	}
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName, nil
 	}
+	return cert.Subject.String(), nil
 }
 
 func checkFilePermissions(filename string) bool {
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	pb "github.com/clwg/syswatch/proto"
+)
+
+// maxSignedLifetime is how far in the future a signed command may expire.
+// Command IDs are remembered until they expire, so this bounds how long.
+const maxSignedLifetime = 24 * time.Hour
+
+// commandVerifier only lets through commands signed by the operator key for
+// this agent that have not expired and have not been run before. The IDs of
+// commands that have been queued to run are kept in seenFile so that they
+// cannot be replayed after a restart either.
+type commandVerifier struct {
+	key      ed25519.PublicKey
+	agentID  string
+	seenFile string
+
+	mu   sync.Mutex
+	seen map[string]int64 // Command ID to when it expires, in Unix seconds
+}
+
+// newCommandVerifier loads the IDs of commands already run from seenFile,
+// which need not exist yet.
+func newCommandVerifier(key ed25519.PublicKey, agentID, seenFile string) (*commandVerifier, error) {
+	v := &commandVerifier{key: key, agentID: agentID, seenFile: seenFile, seen: make(map[string]int64)}
+	data, err := os.ReadFile(seenFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &v.seen); err != nil {
+			return nil, fmt.Errorf("%s: %v", seenFile, err)
+		}
+	}
+	return v, nil
+}
+
+// verify reports why command must not run, if it must not.
+func (v *commandVerifier) verify(command *pb.Command) error {
+	if len(command.GetSignature()) == 0 {
+		return fmt.Errorf("unsigned command")
+	}
+	if command.GetTarget() != v.agentID {
+		return fmt.Errorf("signed for agent %s", command.GetTarget())
+	}
+
+	now := time.Now()
+	expires := time.Unix(command.GetExpiresAt(), 0)
+	if !now.Before(expires) {
+		return fmt.Errorf("signature expired at %s", expires.UTC().Format(time.RFC3339))
+	}
+	if expires.Sub(now) > maxSignedLifetime {
+		return fmt.Errorf("signature expires more than %v from now", maxSignedLifetime)
+	}
+	if err := command.SignedFields().Verify(v.key, command.GetSignature()); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, replayed := v.seen[command.GetCommandId()]; replayed {
+		return fmt.Errorf("command %s was already run", command.GetCommandId())
+	}
+	return nil
+}
+
+// remember records that a verified command has been queued to run, so that
+// it is refused from then on. Commands that are denied or rejected as busy
+// are not remembered and can be sent again until they expire.
+func (v *commandVerifier) remember(command *pb.Command) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now().Unix()
+	for id, expiry := range v.seen {
+		if expiry <= now {
+			delete(v.seen, id)
+		}
+	}
+	v.seen[command.GetCommandId()] = command.GetExpiresAt()
+
+	data, err := json.Marshal(v.seen)
+	if err != nil {
+		return err
+	}
+	temp := v.seenFile + ".tmp"
+	if err := os.WriteFile(temp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, v.seenFile)
+}
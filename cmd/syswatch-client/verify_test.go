@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/clwg/syswatch/proto"
+)
+
+func signedCommand(t *testing.T, key ed25519.PrivateKey, target string, expires time.Time) *pb.Command {
+	t.Helper()
+	command := &pb.Command{CommandId: "id-" + target, Command: "uptime", Target: target, ExpiresAt: expires.Unix()}
+	command.Signature = command.SignedFields().Sign(key)
+	return command
+}
+
+func TestCommandVerifier(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seenFile := filepath.Join(t.TempDir(), "agent.seen.json")
+	v, err := newCommandVerifier(public, "agent", seenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	soon := time.Now().Add(time.Minute)
+
+	tampered := signedCommand(t, private, "agent", soon)
+	tampered.Command = "id"
+	tests := []struct {
+		name    string
+		command *pb.Command
+	}{
+		{"unsigned", &pb.Command{CommandId: "id", Command: "uptime", Target: "agent", ExpiresAt: soon.Unix()}},
+		{"other agent", signedCommand(t, private, "other", soon)},
+		{"expired", signedCommand(t, private, "agent", time.Now().Add(-time.Second))},
+		{"too far ahead", signedCommand(t, private, "agent", time.Now().Add(2*maxSignedLifetime))},
+		{"tampered", tampered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.verify(tt.command); err == nil {
+				t.Error("verify() succeeded, want an error")
+			}
+		})
+	}
+
+	command := signedCommand(t, private, "agent", soon)
+	if err := v.verify(command); err != nil {
+		t.Fatalf("verify() = %v", err)
+	}
+	// A command that was not queued, for example because it was denied, can
+	// be sent again.
+	if err := v.verify(command); err != nil {
+		t.Fatalf("verify() before remember = %v", err)
+	}
+	if err := v.remember(command); err != nil {
+		t.Fatalf("remember() = %v", err)
+	}
+	if err := v.verify(command); err == nil {
+		t.Error("verify() of a replayed command succeeded")
+	}
+
+	restarted, err := newCommandVerifier(public, "agent", seenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.verify(command); err == nil {
+		t.Error("verify() of a command replayed after a restart succeeded")
+	}
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	cryptotls "crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"strconv"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 
 	logwriter "github.com/clwg/go-rotating-logger"
 	"github.com/clwg/syswatch/data"
@@ -21,17 +26,42 @@ var (
 	tls            = flag.Bool("tls", false, "Connection uses TLS if true, else plain TCP")
 	certFile       = flag.String("cert_file", "", "The TLS cert file")
 	keyFile        = flag.String("key_file", "", "The TLS key file")
+	clientCAFile   = flag.String("client_ca_file", "", "The CA cert used to verify agent certificates, enables mutual TLS if set")
 	port           = flag.Int("port", 51001, "The server port")
 	httpPort       = flag.Int("http_port", 8084, "The HTTP server port")
+	httpHost       = flag.String("http_host", "localhost", "The address the HTTP API listens on, empty for all interfaces")
+	httpTLS        = flag.Bool("http_tls", false, "Serve the HTTP API over TLS with the server key pair")
 	filenamePrefix = flag.String("log_filename_prefix", "syswatch", "The prefix for the log file name")
 	logDir         = flag.String("log_dir", "./logs", "The directory for the log files")
 	maxLines       = flag.Int("log_max_lines", 1000, "The maximum number of lines per log file")
 	rotationTime   = flag.Duration("log_rotation_time", 600*time.Second, "The rotation time for the log files")
+	apiTokensFile  = flag.String("api_tokens", "", "File containing operator:token pairs allowed to use the HTTP API")
+	heartbeat      = flag.Duration("heartbeat_interval", 15*time.Second, "How often agents are expected to send a heartbeat")
+	missedBeats    = flag.Int("missed_heartbeats", 3, "The number of heartbeats an agent may miss before it is evicted")
+	templatesFile  = flag.String("templates_file", "", "JSON file of named command templates operators can dispatch")
+	auditFile      = flag.String("audit_file", "", "Append-only file every command issued and its outcome are recorded in, disabled if empty")
 )
 
+// keepaliveParams has the transport ping idle connections so dead peers are
+// noticed even when no heartbeat is due.
+var keepaliveParams = keepalive.ServerParameters{
+	Time:    30 * time.Second,
+	Timeout: 10 * time.Second,
+}
+
+// keepalivePolicy must allow the client keepalive interval in syswatch-client.
+var keepalivePolicy = keepalive.EnforcementPolicy{
+	MinTime:             10 * time.Second,
+	PermitWithoutStream: true,
+}
+
 func main() {
 	flag.Parse()
 
+	if *clientCAFile != "" && !*tls {
+		log.Fatalf("-client_ca_file requires -tls, mutual TLS cannot be enabled over plain TCP")
+	}
+
 	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", *port))
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
@@ -50,30 +80,100 @@ func main() {
 		panic(err)
 	}
 
-	var opts []grpc.ServerOption
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepaliveParams),
+		grpc.KeepaliveEnforcementPolicy(keepalivePolicy),
+	}
+	if *certFile == "" {
+		*certFile = data.Path("data/x509/server_cert.pem")
+	}
+	if *keyFile == "" {
+		*keyFile = data.Path("data/x509/server_key.pem")
+	}
 	if *tls {
-		if *certFile == "" {
-			*certFile = data.Path("data/x509/server_cert.pem")
-		}
-		if *keyFile == "" {
-			*keyFile = data.Path("data/x509/server_key.pem")
-		}
-		creds, err := credentials.NewServerTLSFromFile(*certFile, *keyFile)
+		tlsConfig, err := serverTLSConfig(*certFile, *keyFile, *clientCAFile)
 		if err != nil {
 			log.Fatalf("Failed to generate credentials: %v", err)
 		}
-		opts = []grpc.ServerOption{grpc.Creds(creds)}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	var apiTokens syswatch.APITokens
+	if *apiTokensFile != "" {
+		apiTokens, err = syswatch.LoadAPITokens(*apiTokensFile)
+		if err != nil {
+			log.Fatalf("Failed to load API tokens: %v", err)
+		}
 	}
 
 	grpcServer := grpc.NewServer(opts...)
-	server := syswatch.InitializeSysWatchServer(fileLogger)
+	var templates syswatch.CommandTemplates
+	if *templatesFile != "" {
+		templates, err = syswatch.LoadCommandTemplates(*templatesFile)
+		if err != nil {
+			log.Fatalf("Failed to load command templates: %v", err)
+		}
+		log.Printf("Loaded %d command templates", len(templates))
+	}
+
+	var audit *syswatch.AuditLog
+	if *auditFile != "" {
+		audit, err = syswatch.OpenAuditLog(*auditFile)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		log.Printf("Auditing commands to %s", *auditFile)
+	}
+
+	server := syswatch.InitializeSysWatchServer(syswatch.ServerConfig{
+		Logger:            fileLogger,
+		APITokens:         apiTokens,
+		HeartbeatInterval: *heartbeat,
+		MissedHeartbeats:  *missedBeats,
+		Templates:         templates,
+		Audit:             audit,
+	})
 
 	pb.RegisterSysWatchServer(grpcServer, server)
 
-	go syswatch.StartHTTPServer(server, *httpPort)
+	var httpTLSConfig *cryptotls.Config
+	if *httpTLS {
+		cert, err := cryptotls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load HTTP API credentials: %v", err)
+		}
+		httpTLSConfig = &cryptotls.Config{Certificates: []cryptotls.Certificate{cert}, MinVersion: cryptotls.VersionTLS12}
+	}
+	go syswatch.StartHTTPServer(server, net.JoinHostPort(*httpHost, strconv.Itoa(*httpPort)), httpTLSConfig)
 
 	log.Printf("Server listening at %v", lis.Addr())
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// serverTLSConfig loads the server key pair and, when clientCAFile is set,
+// requires every agent to present a certificate signed by that CA.
+func serverTLSConfig(certFile, keyFile, clientCAFile string) (*cryptotls.Config, error) {
+	cert, err := cryptotls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &cryptotls.Config{Certificates: []cryptotls.Certificate{cert}}
+
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = cryptotls.RequireAndVerifyClientCert
+		log.Println("Mutual TLS enabled, agents must present a client certificate")
+	}
+
+	return config, nil
+}
@@ -0,0 +1,98 @@
+// syswatch-sign signs commands for agents that only run commands signed with a
+// pinned operator key, and generates that key.
+//
+// It reads a /send request body on stdin and writes it to stdout with the
+// command ID, target, expiry and signature added:
+//
+//	echo '{"program":"uptime","wait":true}' | syswatch-sign -key operator.pem -target <agent ID> |
+//		curl -H "Authorization: Bearer $SYSWATCH_TOKEN" -d @- http://localhost:8084/send
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	syswatch "github.com/clwg/syswatch/internal"
+	"github.com/clwg/syswatch/utils"
+)
+
+var (
+	genKey     = flag.Bool("genkey", false, "Generate a key pair, writing the private key to -key and the public key to -pubkey")
+	keyFile    = flag.String("key", "operator.pem", "The PEM file of the Ed25519 private key commands are signed with")
+	pubkeyFile = flag.String("pubkey", "operator.pub.pem", "The PEM file the public key is written to by -genkey, for agents' -command_pubkey")
+	target     = flag.String("target", "", "The agent ID of the agent the command is for")
+	expires    = flag.Duration("expires", 5*time.Minute, "How long the signed command may be run for")
+)
+
+func main() {
+	flag.Parse()
+
+	if *genKey {
+		if err := generateKey(*keyFile, *pubkeyFile); err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		log.Printf("Wrote private key to %s and public key to %s", *keyFile, *pubkeyFile)
+		return
+	}
+
+	if *target == "" {
+		log.Fatalf("-target is required")
+	}
+	key, err := utils.LoadPrivateKey(*keyFile)
+	if err != nil {
+		log.Fatalf("Failed to load key: %v", err)
+	}
+
+	body, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatalf("Failed to read request: %v", err)
+	}
+	signed, err := syswatch.SignRequest(body, key, *target, time.Now().Add(*expires))
+	if err != nil {
+		log.Fatalf("Failed to sign request: %v", err)
+	}
+	if _, err := os.Stdout.Write(append(signed, '\n')); err != nil {
+		log.Fatalf("Failed to write request: %v", err)
+	}
+}
+
+// generateKey writes a new Ed25519 private key to keyFile and its public key
+// to pubkeyFile, both PEM encoded.
+func generateKey(keyFile, pubkeyFile string) error {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return err
+	}
+
+	if err := writePEM(keyFile, "PRIVATE KEY", privateDER, 0600); err != nil {
+		return err
+	}
+	return writePEM(pubkeyFile, "PUBLIC KEY", publicDER, 0644)
+}
+
+func writePEM(filename, blockType string, der []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(file, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
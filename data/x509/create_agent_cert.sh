@@ -0,0 +1,35 @@
+#!/bin/bash
+
+# Generate a certificate for a single agent, signed by the client CA created
+# by create.sh. The agent name becomes the certificate CN, which the server
+# binds the agent's connection ID to when running with -client_ca_file.
+#
+#   sh create_agent_cert.sh web-01
+
+if [ -z "$1" ]; then
+  echo "usage: $0 <agent-name>" >&2
+  exit 1
+fi
+AGENT=$1
+
+openssl genrsa -out ${AGENT}_key.pem 4096
+openssl req -new                                    \
+  -key ${AGENT}_key.pem                             \
+  -days 3650                                        \
+  -out ${AGENT}_csr.pem                             \
+  -subj /C=US/ST=CA/L=SVL/O=gRPC/CN=${AGENT}/       \
+  -config ./openssl.cnf                             \
+  -reqexts test_client
+openssl x509 -req           \
+  -in ${AGENT}_csr.pem      \
+  -CAkey client_ca_key.pem  \
+  -CA client_ca_cert.pem    \
+  -days 3650                \
+  -set_serial $(date +%s)   \
+  -out ${AGENT}_cert.pem    \
+  -extfile ./openssl.cnf    \
+  -extensions test_client   \
+  -sha256
+openssl verify -verbose -CAfile client_ca_cert.pem ${AGENT}_cert.pem
+
+rm ${AGENT}_csr.pem
@@ -6,6 +6,7 @@ require (
 	github.com/clwg/go-rotating-logger v0.0.0-20240609145829-410ae55aae28
 	github.com/google/uuid v1.6.0
 	github.com/hpcloud/tail v1.0.0
+	golang.org/x/sys v0.18.0
 	google.golang.org/grpc v1.63.2
 	google.golang.org/protobuf v1.33.0
 )
@@ -13,7 +14,6 @@ require (
 require (
 	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	golang.org/x/net v0.22.0 // indirect
-	golang.org/x/sys v0.18.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240415180920-8c6c420018be // indirect
 	gopkg.in/fsnotify.v1 v1.4.7 // indirect
@@ -0,0 +1,206 @@
+package syswatch
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/clwg/syswatch/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// agentIDMetadataKey is the gRPC metadata key agents use to present their
+// persistent agent ID when opening a stream.
+const agentIDMetadataKey = "syswatch-agent-id"
+
+// agentMetadata is the host information an agent reports when it registers.
+type agentMetadata struct {
+	Hostname      string            `json:"hostname"`
+	OS            string            `json:"os"`
+	Arch          string            `json:"arch"`
+	KernelVersion string            `json:"kernel_version"`
+	AgentVersion  string            `json:"agent_version"`
+	IPAddresses   []string          `json:"ip_addresses"`
+	Files         []string          `json:"files"`
+	Labels        map[string]string `json:"labels"`
+}
+
+func newAgentMetadata(reg *pb.Registration) agentMetadata {
+	return agentMetadata{
+		Hostname:      reg.GetHostname(),
+		OS:            reg.GetOs(),
+		Arch:          reg.GetArch(),
+		KernelVersion: reg.GetKernelVersion(),
+		AgentVersion:  reg.GetAgentVersion(),
+		IPAddresses:   reg.GetIpAddresses(),
+		Files:         reg.GetFiles(),
+		Labels:        reg.GetLabels(),
+	}
+}
+
+// agentRecord tracks an agent across the transient connections it makes.
+type agentRecord struct {
+	mu               sync.Mutex
+	agentID          string
+	identity         string
+	metadata         agentMetadata
+	firstSeen        time.Time
+	lastSeen         time.Time
+	connectionID     string
+	connectedSince   time.Time
+	lastDisconnected time.Time
+	connections      int
+}
+
+// agentSnapshot is the JSON view of an agent.
+type agentSnapshot struct {
+	AgentID          string     `json:"agent_id"`
+	Identity         string     `json:"identity,omitempty"`
+	Connected        bool       `json:"connected"`
+	ConnectionID     string     `json:"connection_id,omitempty"`
+	FirstSeen        time.Time  `json:"first_seen"`
+	LastSeen         time.Time  `json:"last_seen"`
+	ConnectedSince   *time.Time `json:"connected_since,omitempty"`
+	LastDisconnected *time.Time `json:"last_disconnected,omitempty"`
+	Reconnects       int        `json:"reconnects"`
+	agentMetadata
+}
+
+func (a *agentRecord) snapshot() agentSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	snap := agentSnapshot{
+		AgentID:       a.agentID,
+		Identity:      a.identity,
+		Connected:     a.connectionID != "",
+		ConnectionID:  a.connectionID,
+		FirstSeen:     a.firstSeen,
+		LastSeen:      a.lastSeen,
+		Reconnects:    a.connections - 1,
+		agentMetadata: a.metadata,
+	}
+	if a.connectionID != "" {
+		connectedSince := a.connectedSince
+		snap.ConnectedSince = &connectedSince
+	}
+	if !a.lastDisconnected.IsZero() {
+		lastDisconnected := a.lastDisconnected
+		snap.LastDisconnected = &lastDisconnected
+	}
+	return snap
+}
+
+// agentIDFromContext returns the agent ID presented in the stream metadata.
+func agentIDFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(agentIDMetadataKey); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// attachAgent records connID as the live connection of agentID. An agent may
+// only hold one connection at a time. With mutual TLS the agent ID is the
+// certificate identity; without it an agent ID that has been seen with a
+// client certificate cannot be taken over by a connection without one.
+//
+// A reconnecting agent replaces its previous connection, which may be
+// half-open, when it presents the same certificate, or without mutual TLS
+// once the previous connection has missed a heartbeat.
+func (s *SysWatchServer) attachAgent(agentID, identity, connID string) error {
+	value, _ := s.agents.LoadOrStore(agentID, &agentRecord{agentID: agentID, identity: identity, firstSeen: time.Now()})
+	agent := value.(*agentRecord)
+
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	if agent.identity != identity {
+		return status.Errorf(codes.PermissionDenied, "agent ID %s is bound to identity %q", agentID, agent.identity)
+	}
+	if agent.connectionID != "" {
+		silent := s.heartbeatInterval > 0 && time.Since(agent.lastSeen) > s.heartbeatInterval
+		if identity == "" && !silent {
+			return status.Errorf(codes.AlreadyExists, "agent ID %s is already connected as %s", agentID, agent.connectionID)
+		}
+		log.Printf("Agent %s reconnected, replacing connection ID %s with %s", agentID, agent.connectionID, connID)
+		s.evictConnection(agent.connectionID, status.Errorf(codes.Aborted, "agent ID %s reconnected as connection ID %s", agentID, connID))
+		agent.lastDisconnected = time.Now()
+	}
+
+	agent.connectionID = connID
+	agent.connectedSince = time.Now()
+	agent.lastSeen = agent.connectedSince
+	agent.connections++
+	return nil
+}
+
+// touchAgent records that a message arrived from agentID, updating its
+// metadata when the message is a registration.
+func (s *SysWatchServer) touchAgent(agentID string, reg *pb.Registration) {
+	value, ok := s.agents.Load(agentID)
+	if !ok {
+		return
+	}
+	agent := value.(*agentRecord)
+
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	agent.lastSeen = time.Now()
+	if reg != nil {
+		agent.metadata = newAgentMetadata(reg)
+	}
+}
+
+// detachAgent clears connID from its agent once the connection goes away.
+func (s *SysWatchServer) detachAgent(agentID, connID string) {
+	value, ok := s.agents.Load(agentID)
+	if !ok {
+		return
+	}
+	agent := value.(*agentRecord)
+
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	if agent.connectionID == connID {
+		agent.connectionID = ""
+		agent.lastDisconnected = time.Now()
+	}
+}
+
+// resolveConnection maps an agent ID to its live connection ID. Any other
+// value is assumed to already be a connection ID.
+func (s *SysWatchServer) resolveConnection(id string) string {
+	value, ok := s.agents.Load(id)
+	if !ok {
+		return id
+	}
+	agent := value.(*agentRecord)
+
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	if agent.connectionID == "" {
+		return id
+	}
+	return agent.connectionID
+}
+
+func (s *SysWatchServer) getAgents() []agentSnapshot {
+	agents := []agentSnapshot{}
+	s.agents.Range(func(key, value interface{}) bool {
+		agents = append(agents, value.(*agentRecord).snapshot())
+		return true
+	})
+	sort.Slice(agents, func(i, j int) bool { return agents[i].AgentID < agents[j].AgentID })
+	return agents
+}
@@ -0,0 +1,101 @@
+package syswatch
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// attachTestConnection registers a stream for connID and attaches it to agentID.
+func attachTestConnection(t *testing.T, s *SysWatchServer, agentID, identity, connID string) (*connectionStream, error) {
+	t.Helper()
+	connStream := &connectionStream{connID: connID, agentID: agentID, identity: identity, evicted: make(chan struct{})}
+	s.clients.Store(connID, connStream)
+	if err := s.attachAgent(agentID, identity, connID); err != nil {
+		s.clients.Delete(connID)
+		return nil, err
+	}
+	return connStream, nil
+}
+
+func setAgentLastSeen(s *SysWatchServer, agentID string, lastSeen time.Time) {
+	value, _ := s.agents.Load(agentID)
+	agent := value.(*agentRecord)
+	agent.mu.Lock()
+	agent.lastSeen = lastSeen
+	agent.mu.Unlock()
+}
+
+func TestAttachAgentTakeover(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity string
+		silent   bool
+		wantCode codes.Code // codes.OK when the new connection replaces the old
+	}{
+		{"same certificate", "web-01", false, codes.OK},
+		{"no certificate, old connection silent", "", true, codes.OK},
+		{"no certificate, old connection alive", "", false, codes.AlreadyExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, ServerConfig{HeartbeatInterval: time.Hour, MissedHeartbeats: 3})
+			old, err := attachTestConnection(t, s, "web-01", tt.identity, "old")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.silent {
+				setAgentLastSeen(s, "web-01", time.Now().Add(-2*time.Hour))
+			}
+
+			_, err = attachTestConnection(t, s, "web-01", tt.identity, "new")
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("attaching a second connection = %v, want code %v", err, tt.wantCode)
+			}
+
+			_, oldRegistered := s.clients.Load("old")
+			if tt.wantCode != codes.OK {
+				if !oldRegistered || s.resolveConnection("web-01") != "old" {
+					t.Error("the old connection was replaced")
+				}
+				return
+			}
+			select {
+			case <-old.evicted:
+				if status.Code(old.evictErr) != codes.Aborted {
+					t.Errorf("old connection evicted with %v, want code Aborted", old.evictErr)
+				}
+			default:
+				t.Fatal("the old connection was not evicted")
+			}
+			if oldRegistered {
+				t.Error("the old connection is still registered")
+			}
+			if conn := s.resolveConnection("web-01"); conn != "new" {
+				t.Errorf("agent resolves to connection %s, want new", conn)
+			}
+
+			// The old connection going away afterwards leaves the new one attached.
+			s.detachAgent("web-01", "old")
+			if conn := s.resolveConnection("web-01"); conn != "new" {
+				t.Errorf("after the old connection closed, agent resolves to %s, want new", conn)
+			}
+		})
+	}
+}
+
+func TestAttachAgentOtherIdentity(t *testing.T) {
+	s := newTestServer(t, ServerConfig{})
+	if _, err := attachTestConnection(t, s, "web-01", "web-01", "old"); err != nil {
+		t.Fatal(err)
+	}
+	setAgentLastSeen(s, "web-01", time.Now().Add(-time.Hour))
+
+	// A silent connection does not let a client without the certificate in.
+	_, err := attachTestConnection(t, s, "web-01", "", "new")
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("attaching without the certificate = %v, want code PermissionDenied", err)
+	}
+}
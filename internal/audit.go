@@ -0,0 +1,329 @@
+package syswatch
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Audit events.
+const (
+	auditIssued    = "issued"    // An operator dispatched a command to an agent
+	auditFailed    = "failed"    // The command could not be delivered to the agent
+	auditCancelled = "cancelled" // An operator asked the agent to cancel a command
+	auditResult    = "result"    // The agent replied with the command's outcome
+	auditLost      = "lost"      // The connection closed before the agent replied
+)
+
+// defaultAuditLimit is how many of the most recent matching entries /audit
+// returns when no limit is given.
+const defaultAuditLimit = 100
+
+// auditEntry is a single line of the audit log. Each entry's hash covers the
+// entry itself, including the hash of the entry before it, so editing,
+// removing or reordering entries breaks the chain from that point on.
+type auditEntry struct {
+	Seq          uint64    `json:"seq"`
+	Time         time.Time `json:"time"`
+	Event        string    `json:"event"`
+	Operator     string    `json:"operator,omitempty"`
+	CommandID    string    `json:"command_id"`
+	ConnectionID string    `json:"connection_id,omitempty"`
+	AgentID      string    `json:"agent_id,omitempty"`
+	Command      string    `json:"command,omitempty"`
+	User         string    `json:"user,omitempty"`
+	Template     string    `json:"template,omitempty"`
+	Signed       bool      `json:"signed,omitempty"`
+
+	Status   string `json:"status,omitempty"` // Outcome of a result, as for broadcast job endpoints
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Signal   string `json:"signal,omitempty"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// computeHash returns the hex SHA-256 of the entry encoded without its hash.
+func (e auditEntry) computeHash() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditLog is an append-only, hash-chained JSON lines file of the commands
+// operators issue and their outcomes.
+type AuditLog struct {
+	filename string
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64 // Bytes of whole entries written, which is all a reader may read
+	seq    uint64
+	head   string // Hash of the last entry
+	broken error  // Why a failed append could not be undone, after which nothing more is appended
+}
+
+// OpenAuditLog opens filename for appending, creating it if needed, and
+// continues the chain from its last entry. A chain that fails verification
+// is reported but does not stop the log from being opened.
+func OpenAuditLog(filename string) (*AuditLog, error) {
+	audit := &AuditLog{filename: filename}
+	report, err := audit.read(nil)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if report.Error != "" {
+		log.Printf("Audit log %s failed verification: %s", filename, report.Error)
+	}
+	audit.seq, audit.head = report.Seq, report.Head
+
+	audit.file, err = os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	info, err := audit.file.Stat()
+	if err != nil {
+		audit.file.Close()
+		return nil, err
+	}
+	audit.size = info.Size()
+	return audit, nil
+}
+
+// record appends entries to the log, each chained to the entry before it.
+// Entries are synced to disk, all at once, before record returns. When they
+// cannot be, whatever part of them was written is removed again so the next
+// entry still follows the last whole one.
+func (a *AuditLog) record(entries ...*auditEntry) error {
+	if a == nil || len(entries) == 0 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.broken != nil {
+		return fmt.Errorf("audit log is unusable: %w", a.broken)
+	}
+
+	var data []byte
+	seq, head := a.seq, a.head
+	for _, entry := range entries {
+		seq++
+		entry.Seq = seq
+		entry.Time = time.Now().UTC()
+		entry.PrevHash = head
+		entry.Hash = entry.computeHash()
+		head = entry.Hash
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	if _, err := a.file.Write(data); err != nil {
+		a.rollback()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := a.file.Sync(); err != nil {
+		a.rollback()
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	a.size += int64(len(data))
+	a.seq, a.head = seq, head
+	return nil
+}
+
+// rollback truncates the log back to its last whole entry after a failed
+// append, or marks the log broken if it cannot.
+func (a *AuditLog) rollback() {
+	err := a.file.Truncate(a.size)
+	if err == nil {
+		err = a.file.Sync()
+	}
+	if err != nil {
+		log.Printf("Failed to remove a partly written entry from audit log %s, refusing further entries: %v", a.filename, err)
+		a.broken = err
+	}
+}
+
+// auditReport is the result of reading and verifying the audit log.
+type auditReport struct {
+	Verified bool          `json:"verified"`
+	Error    string        `json:"error,omitempty"` // First point at which the chain is broken
+	Seq      uint64        `json:"seq"`             // Sequence number of the last entry
+	Head     string        `json:"head"`            // Hash of the last entry
+	Entries  []*auditEntry `json:"entries"`
+}
+
+// read verifies the whole log and collects the entries keep accepts, or none
+// when keep is nil.
+func (a *AuditLog) read(keep func(*auditEntry) bool) (*auditReport, error) {
+	report := &auditReport{Entries: []*auditEntry{}}
+
+	file, err := os.Open(a.filename)
+	if err != nil {
+		return report, err
+	}
+	defer file.Close()
+
+	// Only read what had been written when reading began, so as not to see an
+	// entry that is still being written.
+	var input io.Reader = file
+	a.mu.Lock()
+	if a.file != nil {
+		input = io.LimitReader(file, a.size)
+	}
+	a.mu.Unlock()
+
+	reader := bufio.NewReader(input)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(data) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return report, err
+		}
+		data = bytes.TrimSuffix(data, []byte("\n"))
+
+		var entry auditEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			report.fail(fmt.Sprintf("line %d is not an audit entry", line))
+			continue
+		}
+
+		// Re-encoding must reproduce the line exactly, so that nothing can be
+		// added to an entry without changing its hash.
+		canonical, _ := json.Marshal(entry)
+		switch {
+		case !bytes.Equal(canonical, data) || entry.computeHash() != entry.Hash:
+			report.fail(fmt.Sprintf("entry %d on line %d has been modified", entry.Seq, line))
+		case entry.Seq != report.Seq+1 || entry.PrevHash != report.Head:
+			report.fail(fmt.Sprintf("entry %d on line %d does not follow entry %d", entry.Seq, line, report.Seq))
+		}
+		report.Seq, report.Head = entry.Seq, entry.Hash
+
+		if keep != nil && keep(&entry) {
+			report.Entries = append(report.Entries, &entry)
+		}
+	}
+
+	report.Verified = report.Error == ""
+	return report, nil
+}
+
+func (r *auditReport) fail(reason string) {
+	if r.Error == "" {
+		r.Error = reason
+	}
+}
+
+// issuedEntry is the audit entry for operator dispatching a command to
+// connStream.
+func issuedEntry(connStream *connectionStream, commandID string, spec commandSpec, operator string) *auditEntry {
+	return &auditEntry{
+		Event:        auditIssued,
+		Operator:     operator,
+		CommandID:    commandID,
+		ConnectionID: connStream.connID,
+		AgentID:      connStream.agentID,
+		Command:      spec.Message,
+		User:         spec.User,
+		Template:     spec.Template,
+		Signed:       spec.signed(),
+	}
+}
+
+// recordOutcome records how a dispatched command ended, logging rather than
+// returning any failure since the command has already run.
+func (s *SysWatchServer) recordOutcome(event, commandID string, pending *pendingCommand, result *commandResult) {
+	entry := &auditEntry{
+		Event:        event,
+		Operator:     pending.operator,
+		CommandID:    commandID,
+		ConnectionID: pending.connID,
+		AgentID:      pending.agentID,
+	}
+	if event == auditResult {
+		entry.Status = resultState(result)
+	}
+	if result != nil {
+		exitCode := result.ExitCode
+		entry.ExitCode = &exitCode
+		entry.Signal = result.Signal
+		entry.TimedOut = result.TimedOut
+		entry.Error = result.Error
+	}
+	if err := s.audit.record(entry); err != nil {
+		log.Printf("Failed to audit %s of command %s: %v", event, commandID, err)
+	}
+}
+
+// apiAudit reports audit log entries, most recent last, along with whether
+// the whole log verifies. Entries can be filtered by operator, agent (agent or
+// connection ID), command_id, event and since (RFC 3339), and limit caps how
+// many of the most recent matches are returned.
+func (s *SysWatchServer) apiAudit(w http.ResponseWriter, r *http.Request) {
+	if s.audit == nil {
+		http.Error(w, "Audit log is not enabled", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "Invalid since, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+	limit := defaultAuditLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	matches := func(value, filter string) bool { return filter == "" || value == filter }
+	report, err := s.audit.read(func(entry *auditEntry) bool {
+		agent := query.Get("agent")
+		return matches(entry.Operator, query.Get("operator")) &&
+			(agent == "" || entry.AgentID == agent || entry.ConnectionID == agent) &&
+			matches(entry.CommandID, query.Get("command_id")) &&
+			matches(entry.Event, query.Get("event")) &&
+			!entry.Time.Before(since)
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "Failed to read audit log", http.StatusInternalServerError)
+		return
+	}
+	if len(report.Entries) > limit {
+		report.Entries = report.Entries[len(report.Entries)-limit:]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package syswatch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeAuditLog records count entries, the last two in a single batch, and
+// returns the log's lines.
+func writeAuditLog(t *testing.T, filename string, count int) [][]byte {
+	t.Helper()
+	audit, err := OpenAuditLog(filename)
+	if err != nil {
+		t.Fatalf("OpenAuditLog() = %v", err)
+	}
+	defer audit.file.Close()
+
+	for i := 0; i < count-2; i++ {
+		if err := audit.record(&auditEntry{Event: auditIssued, Operator: "alice", CommandID: "c1", Command: "uptime"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = audit.record(
+		&auditEntry{Event: auditIssued, Operator: "bob", CommandID: "c2", Command: "df -h"},
+		&auditEntry{Event: auditIssued, Operator: "bob", CommandID: "c3", Command: "df -h"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.SplitAfter(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+}
+
+func verifyAuditLog(t *testing.T, filename string) *auditReport {
+	t.Helper()
+	report, err := (&AuditLog{filename: filename}).read(func(*auditEntry) bool { return true })
+	if err != nil {
+		t.Fatalf("read() = %v", err)
+	}
+	return report
+}
+
+func TestAuditLogVerifies(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeAuditLog(t, filename, 4)
+
+	report := verifyAuditLog(t, filename)
+	if !report.Verified || report.Seq != 4 || len(report.Entries) != 4 {
+		t.Fatalf("report = verified %v, seq %d, %d entries, error %q; want 4 verified entries", report.Verified, report.Seq, len(report.Entries), report.Error)
+	}
+
+	// Reopening continues the chain.
+	audit, err := OpenAuditLog(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := audit.record(&auditEntry{Event: auditCancelled, CommandID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	audit.file.Close()
+	if report := verifyAuditLog(t, filename); !report.Verified || report.Seq != 5 {
+		t.Errorf("after reopening, verified %v at seq %d (%s), want 5 verified entries", report.Verified, report.Seq, report.Error)
+	}
+}
+
+func TestAuditLogDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(lines [][]byte) [][]byte
+		wantErr string
+	}{
+		{"edited", func(lines [][]byte) [][]byte {
+			lines[1] = bytes.Replace(lines[1], []byte(`"alice"`), []byte(`"mallory"`), 1)
+			return lines
+		}, "entry 2 on line 2 has been modified"},
+		{"field added", func(lines [][]byte) [][]byte {
+			lines[1] = bytes.Replace(lines[1], []byte(`{`), []byte(`{"note":"x",`), 1)
+			return lines
+		}, "entry 2 on line 2 has been modified"},
+		{"removed", func(lines [][]byte) [][]byte {
+			return append(lines[:1], lines[2:]...)
+		}, "entry 3 on line 2 does not follow entry 1"},
+		{"reordered", func(lines [][]byte) [][]byte {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}, "entry 3 on line 2 does not follow entry 1"},
+		{"batch reordered", func(lines [][]byte) [][]byte {
+			lines[3], lines[4] = lines[4], lines[3]
+			return lines
+		}, "entry 5 on line 4 does not follow entry 3"},
+		{"not an entry", func(lines [][]byte) [][]byte {
+			lines[0] = []byte("garbage\n")
+			return lines
+		}, "line 1 is not an audit entry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "audit.jsonl")
+			lines := tt.tamper(writeAuditLog(t, filename, 5))
+			for i, line := range lines {
+				if !bytes.HasSuffix(line, []byte("\n")) {
+					lines[i] = append(line, '\n')
+				}
+			}
+			if err := os.WriteFile(filename, bytes.Join(lines, nil), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			report := verifyAuditLog(t, filename)
+			if report.Verified || !strings.Contains(report.Error, tt.wantErr) {
+				t.Errorf("report = verified %v, error %q; want error %q", report.Verified, report.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuditLogRefusesEntriesAfterFailedRollback(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeAuditLog(t, filename, 3)
+	audit, err := OpenAuditLog(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither writing nor truncating a closed file succeeds.
+	audit.file.Close()
+	if err := audit.record(&auditEntry{Event: auditIssued, CommandID: "c4"}); err == nil {
+		t.Fatal("record() to a closed file succeeded")
+	}
+	err = audit.record(&auditEntry{Event: auditIssued, CommandID: "c5"})
+	if err == nil || !strings.Contains(err.Error(), "unusable") {
+		t.Errorf("record() after a failed rollback = %v, want the log refused as unusable", err)
+	}
+	if report := verifyAuditLog(t, filename); !report.Verified || report.Seq != 3 {
+		t.Errorf("verified %v at seq %d (%s), want the 3 original entries", report.Verified, report.Seq, report.Error)
+	}
+}
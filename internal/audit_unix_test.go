@@ -0,0 +1,56 @@
+//go:build !windows
+
+package syswatch
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestAuditLogRollsBackShortWrite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeAuditLog(t, filename, 3)
+	audit, err := OpenAuditLog(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.file.Close()
+	size := audit.size
+
+	// Only part of the next entry fits under the file size limit. Go ignores
+	// the SIGXFSZ this raises, so the write comes up short with EFBIG.
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_FSIZE, &limit); err != nil {
+		t.Fatal(err)
+	}
+	short := limit
+	short.Cur = uint64(size) + 20
+	if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &short); err != nil {
+		t.Skipf("cannot limit file size: %v", err)
+	}
+	err = audit.record(&auditEntry{Event: auditIssued, Operator: "alice", CommandID: "c4", Command: "uptime"})
+	if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &limit); err != nil {
+		t.Fatal(err)
+	}
+	if err == nil {
+		t.Fatal("record() past the file size limit succeeded")
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != size || audit.size != size {
+		t.Errorf("after a short write the file is %d bytes and the log tracks %d, want both %d", info.Size(), audit.size, size)
+	}
+
+	// The next entry follows the last whole one.
+	if err := audit.record(&auditEntry{Event: auditIssued, Operator: "alice", CommandID: "c5", Command: "uptime"}); err != nil {
+		t.Fatalf("record() after the short write = %v", err)
+	}
+	if report := verifyAuditLog(t, filename); !report.Verified || report.Seq != 4 {
+		t.Errorf("verified %v at seq %d (%s), want 4 verified entries", report.Verified, report.Seq, report.Error)
+	}
+}
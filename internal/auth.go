@@ -0,0 +1,83 @@
+package syswatch
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type operatorContextKey struct{}
+
+// APITokens maps the SHA-256 digest of an API token to the operator it identifies.
+type APITokens map[[sha256.Size]byte]string
+
+// LoadAPITokens reads operator tokens from a file containing one
+// "operator:token" pair per line. Blank lines and lines starting with # are ignored.
+func LoadAPITokens(filename string) (APITokens, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tokens := make(APITokens)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		operator, token, ok := strings.Cut(line, ":")
+		operator, token = strings.TrimSpace(operator), strings.TrimSpace(token)
+		if !ok || operator == "" || token == "" {
+			return nil, fmt.Errorf("%s:%d: expected operator:token", filename, lineNum)
+		}
+		tokens[sha256.Sum256([]byte(token))] = operator
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// lookup returns the operator owning token, if any.
+func (t APITokens) lookup(token string) (string, bool) {
+	operator, ok := t[sha256.Sum256([]byte(token))]
+	return operator, ok
+}
+
+// requireOperator rejects requests that do not carry a valid bearer token and
+// records the authenticated operator on the request context.
+func (s *SysWatchServer) requireOperator(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="syswatch"`)
+			http.Error(w, "Missing API token", http.StatusUnauthorized)
+			return
+		}
+
+		operator, ok := s.apiTokens.lookup(token)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="syswatch"`)
+			http.Error(w, "Invalid API token", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), operatorContextKey{}, operator)))
+	}
+}
+
+// operatorFromContext returns the operator authenticated by requireOperator.
+func operatorFromContext(ctx context.Context) string {
+	operator, _ := ctx.Value(operatorContextKey{}).(string)
+	return operator
+}
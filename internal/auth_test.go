@@ -0,0 +1,122 @@
+package syswatch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTokens(t *testing.T, contents string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(filename, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadAPITokens(t *testing.T) {
+	tokens, err := LoadAPITokens(writeTokens(t, "# operators\nalice:s3cret\n\n  bob : t0ken:with:colons  \n"))
+	if err != nil {
+		t.Fatalf("LoadAPITokens() = %v", err)
+	}
+	want := map[string]string{"s3cret": "alice", "t0ken:with:colons": "bob"}
+	if len(tokens) != len(want) {
+		t.Errorf("loaded %d tokens, want %d", len(tokens), len(want))
+	}
+	for token, operator := range want {
+		if got, ok := tokens.lookup(token); !ok || got != operator {
+			t.Errorf("lookup(%q) = %q, %v; want %q", token, got, ok, operator)
+		}
+	}
+	if _, ok := tokens.lookup("alice"); ok {
+		t.Error("an operator name is accepted as a token")
+	}
+}
+
+func TestLoadAPITokensMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"no separator", "alice s3cret\n"},
+		{"no operator", ":s3cret\n"},
+		{"no token", "alice:\n"},
+		{"blank token", "alice:   \n"},
+		{"bad line after good ones", "alice:s3cret\nbob\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadAPITokens(writeTokens(t, tt.contents)); err == nil {
+				t.Error("LoadAPITokens() succeeded, want an error")
+			}
+		})
+	}
+
+	if _, err := LoadAPITokens(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadAPITokens() of a missing file succeeded")
+	}
+}
+
+func TestRequireOperator(t *testing.T) {
+	tokens, err := LoadAPITokens(writeTokens(t, "alice:s3cret\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, ServerConfig{APITokens: tokens})
+	handler := s.requireOperator(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(operatorFromContext(r.Context())))
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantOperator  string
+	}{
+		{"valid token", "Bearer s3cret", http.StatusOK, "alice"},
+		{"no header", "", http.StatusUnauthorized, ""},
+		{"empty token", "Bearer ", http.StatusUnauthorized, ""},
+		{"wrong token", "Bearer wrong", http.StatusUnauthorized, ""},
+		{"wrong scheme", "Basic s3cret", http.StatusUnauthorized, ""},
+		{"operator name", "Bearer alice", http.StatusUnauthorized, ""},
+		{"token prefix", "Bearer s3cre", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/send", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if rec.Body.String() != tt.wantOperator {
+					t.Errorf("operator = %q, want %q", rec.Body.String(), tt.wantOperator)
+				}
+			} else if rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("no WWW-Authenticate header on a rejected request")
+			}
+		})
+	}
+}
+
+func TestRequireOperatorWithoutTokens(t *testing.T) {
+	s := newTestServer(t, ServerConfig{})
+	called := false
+	handler := s.requireOperator(func(http.ResponseWriter, *http.Request) { called = true })
+
+	req := httptest.NewRequest(http.MethodGet, "/connections", nil)
+	req.Header.Set("Authorization", "Bearer anything")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if called || rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, handler called %v; want every request rejected", rec.Code, called)
+	}
+}
@@ -0,0 +1,498 @@
+package syswatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/clwg/syswatch/proto"
+	"github.com/clwg/syswatch/utils"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var (
+	errConnectionNotFound = errors.New("connection ID not found")
+	errCommandNotFound    = errors.New("command ID not found")
+	errCommandIDInUse     = errors.New("command ID already in use")
+	errSendFailed         = errors.New("failed to send command")
+	errSignedTemplate     = errors.New("a signed command cannot be rendered from a template")
+)
+
+// commandSpec is a command an operator asked for through the HTTP API, along
+// with how the agent should run it.
+type commandSpec struct {
+	Message string            `json:"message"`
+	Timeout int               `json:"timeout"` // Seconds the agent lets the command run for
+	Cwd     string            `json:"cwd"`
+	Env     map[string]string `json:"env"`
+	User    string            `json:"user"`
+	Stream  bool              `json:"stream"` // Relay output as the command produces it
+
+	MaxOutputBytes int64          `json:"max_output_bytes"` // Output the agent keeps per stream
+	Limits         *commandLimits `json:"limits"`           // Resource limits, applied where stricter than the agent's own
+
+	Template string            `json:"template"` // Catalog template to render instead of a message
+	Args     map[string]string `json:"args"`
+
+	Shell     bool     `json:"shell"`   // Run the message through the shell rather than splitting it into argv
+	Program   string   `json:"program"` // Executed directly with Arguments instead of a message
+	Arguments []string `json:"arguments"`
+
+	// Set on commands an operator signed, which are passed to the agent as
+	// signed and run under the operator's command ID.
+	CommandID string `json:"command_id"`
+	Target    string `json:"target"`     // Agent ID the command was signed for
+	ExpiresAt int64  `json:"expires_at"` // Unix seconds
+	Signature []byte `json:"signature"`  // Base64 encoded
+}
+
+// commandLimits are the resource limits an operator requested for a command.
+type commandLimits struct {
+	CPUSeconds   int64  `json:"cpu_seconds"`
+	MemoryBytes  int64  `json:"memory_bytes"`
+	MaxProcesses int    `json:"max_processes"`
+	Nice         int    `json:"nice"`
+	IOClass      string `json:"io_class"`
+	IOPriority   int    `json:"io_priority"`
+}
+
+func (l *commandLimits) validate() error {
+	if l.CPUSeconds < 0 {
+		return errors.New("limits: cpu_seconds must not be negative")
+	}
+	limits := utils.ResourceLimits{
+		CPUTime:      time.Duration(l.CPUSeconds) * time.Second,
+		MemoryBytes:  l.MemoryBytes,
+		MaxProcesses: l.MaxProcesses,
+		Nice:         l.Nice,
+		IOClass:      l.IOClass,
+		IOPriority:   l.IOPriority,
+	}
+	if err := limits.Validate(); err != nil {
+		return fmt.Errorf("limits: %w", err)
+	}
+	return nil
+}
+
+func (l *commandLimits) proto() *pb.ResourceLimits {
+	if l == nil {
+		return nil
+	}
+	return &pb.ResourceLimits{
+		CpuSeconds:   uint64(l.CPUSeconds),
+		MemoryBytes:  uint64(l.MemoryBytes),
+		MaxProcesses: uint32(l.MaxProcesses),
+		Nice:         int32(l.Nice),
+		IoClass:      l.IOClass,
+		IoPriority:   int32(l.IOPriority),
+	}
+}
+
+// resolveCommand fills in the message of a command given as a template or as a
+// program with arguments, so every command can be logged and shown the same way.
+func (s *SysWatchServer) resolveCommand(spec *commandSpec) error {
+	if err := spec.resolve(); err != nil {
+		return err
+	}
+	if spec.Template != "" {
+		return s.renderTemplate(spec)
+	}
+	return nil
+}
+
+// resolve checks that c gives a single command and fills in the message of a
+// program with arguments. Templates are left to the server to render.
+func (c *commandSpec) resolve() error {
+	sources := 0
+	for _, set := range []bool{c.Message != "", c.Template != "", c.Program != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("specify only one of message, template or program")
+	}
+	if len(c.Args) > 0 && c.Template == "" {
+		return errors.New("args require a template")
+	}
+	if len(c.Arguments) > 0 && c.Program == "" {
+		return errors.New("arguments require a program")
+	}
+	if c.signed() && c.Template != "" {
+		return errSignedTemplate
+	}
+
+	if c.Program != "" {
+		if c.Shell {
+			return errors.New("a program is never run through the shell")
+		}
+		c.Message = utils.JoinArgv(append([]string{c.Program}, c.Arguments...))
+	}
+	return nil
+}
+
+func (c commandSpec) validate() error {
+	if c.Message == "" {
+		return errors.New("missing message, template or program in request body")
+	}
+	if c.Timeout < 0 || c.MaxOutputBytes < 0 {
+		return errors.New("timeout and max_output_bytes must not be negative")
+	}
+	for key := range c.Env {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return fmt.Errorf("invalid environment variable name %q", key)
+		}
+	}
+	if c.signed() {
+		if c.CommandID == "" || c.Target == "" || c.ExpiresAt == 0 {
+			return errors.New("a signed command requires command_id, target and expires_at")
+		}
+	} else if c.CommandID != "" || c.Target != "" || c.ExpiresAt != 0 {
+		return errors.New("command_id, target and expires_at are only accepted with a signature")
+	}
+	if c.Limits != nil {
+		return c.Limits.validate()
+	}
+	return nil
+}
+
+func (c commandSpec) signed() bool {
+	return len(c.Signature) > 0
+}
+
+// replyTimeout is how long to wait for the reply to c when the caller gave no
+// wait timeout: long enough for the command's own timeout to run out.
+func (c commandSpec) replyTimeout() time.Duration {
+	timeout := time.Duration(c.Timeout)*time.Second + replyGrace
+	if timeout < defaultReplyTimeout {
+		return defaultReplyTimeout
+	}
+	return timeout
+}
+
+// maxOutputBytes is the most output per stream the agent may send for c: what
+// was asked for, or the agent's default.
+func (c commandSpec) maxOutputBytes() int64 {
+	if c.MaxOutputBytes > 0 {
+		return c.MaxOutputBytes
+	}
+	return utils.DefaultMaxOutputBytes
+}
+
+func (c commandSpec) proto(commandID, operator string) *pb.Command {
+	command := &pb.Command{
+		CommandId: commandID,
+		Command:   c.Message,
+		IssuedBy:  operator,
+		Cwd:       c.Cwd,
+		Env:       c.Env,
+		User:      c.User,
+		Stream:    c.Stream,
+		Template:  c.Template,
+		Args:      c.Args,
+		Shell:     c.Shell,
+		Program:   c.Program,
+		Arguments: c.Arguments,
+
+		MaxOutputBytes: uint64(c.MaxOutputBytes),
+		Limits:         c.Limits.proto(),
+		Target:         c.Target,
+		ExpiresAt:      c.ExpiresAt,
+		Signature:      c.Signature,
+	}
+	if c.Timeout > 0 {
+		command.Timeout = durationpb.New(time.Duration(c.Timeout) * time.Second)
+	}
+	return command
+}
+
+// commandResult is the decoded reply an agent sent for a dispatched command.
+type commandResult struct {
+	CommandID  string     `json:"command_id"`
+	Stdout     string     `json:"stdout"`
+	Stderr     string     `json:"stderr"`
+	ExitCode   int        `json:"exit_code"`
+	Signal     string     `json:"signal,omitempty"`
+	TimedOut   bool       `json:"timed_out"`
+	Cancelled  bool       `json:"cancelled"`
+	StartTime  *time.Time `json:"start_time,omitempty"`
+	EndTime    *time.Time `json:"end_time,omitempty"`
+	DurationMs int64      `json:"duration_ms"`
+	Error      string     `json:"error,omitempty"`
+	Rejected   string     `json:"rejected,omitempty"` // Why the agent refused to run the command
+	Denied     string     `json:"denied,omitempty"`   // Why the agent policy forbids the command
+
+	Truncated   bool  `json:"truncated"` // Output past max_output_bytes was discarded
+	StdoutBytes int64 `json:"stdout_bytes"`
+	StderrBytes int64 `json:"stderr_bytes"`
+}
+
+func newCommandResult(result *pb.CommandResult) *commandResult {
+	decoded := &commandResult{
+		CommandID:  result.GetCommandId(),
+		Stdout:     string(result.GetStdout()),
+		Stderr:     string(result.GetStderr()),
+		ExitCode:   int(result.GetExitCode()),
+		Signal:     result.GetSignal(),
+		TimedOut:   result.GetTimedOut(),
+		Cancelled:  result.GetCancelled(),
+		DurationMs: result.GetDuration().AsDuration().Milliseconds(),
+		Error:      result.GetError(),
+
+		Truncated:   result.GetTruncated(),
+		StdoutBytes: int64(result.GetStdoutBytes()),
+		StderrBytes: int64(result.GetStderrBytes()),
+	}
+	if result.GetStartTime() != nil {
+		startTime := result.GetStartTime().AsTime()
+		decoded.StartTime = &startTime
+	}
+	if result.GetEndTime() != nil {
+		endTime := result.GetEndTime().AsTime()
+		decoded.EndTime = &endTime
+	}
+	return decoded
+}
+
+// pendingCommand tracks a dispatched command until its reply arrives.
+type pendingCommand struct {
+	connID   string
+	agentID  string
+	operator string
+	acked    chan struct{} // Closed once the agent acknowledges the command
+	ackOnce  sync.Once
+	stream   bool
+	output   chan *commandOutput // Output of a streaming command, in the order it arrived
+	result   chan *commandResult
+
+	maxOutput int64 // Output per stream collected for a non-streaming command
+
+	// Only touched by the receive loop of connID.
+	droppingOutput  bool
+	stdout          bytes.Buffer // Output of a non-streaming command sent ahead of its result
+	stderr          bytes.Buffer
+	outputTruncated bool // Chunks past maxOutput were discarded
+}
+
+// dispatchCommand sends a command to a single connection and returns the
+// command ID it was issued under, which is the operator's own for a signed
+// command. The issuing operator is recorded in the log and passed to the
+// agent. The command is tracked until its reply arrives or the connection goes
+// away, whether or not the caller waits for the reply.
+func (s *SysWatchServer) dispatchCommand(connID string, spec commandSpec, operator string) (*pendingCommand, string, error) {
+	d := s.dispatchCommands([]string{connID}, spec, operator)[0]
+	return d.pending, d.commandID, d.err
+}
+
+// dispatch is the outcome of sending a command to one connection.
+type dispatch struct {
+	connID     string
+	connStream *connectionStream
+	commandID  string
+	pending    *pendingCommand // Nil when err is set
+	err        error           // Wraps errSendFailed when the stream could not be written
+}
+
+// dispatchCommands sends a command to each of connIDs, as dispatchCommand
+// does. The commands are audited together, with a single sync, before any of
+// them is sent.
+func (s *SysWatchServer) dispatchCommands(connIDs []string, spec commandSpec, operator string) []*dispatch {
+	dispatches := make([]*dispatch, len(connIDs))
+	var issued []*dispatch
+	var entries []*auditEntry
+	for i, connID := range connIDs {
+		d := &dispatch{connID: connID}
+		dispatches[i] = d
+
+		value, ok := s.clients.Load(connID)
+		if !ok {
+			d.err = errConnectionNotFound
+			continue
+		}
+		d.connStream = value.(*connectionStream)
+
+		d.commandID = spec.CommandID
+		if d.commandID == "" {
+			d.commandID = uuid.New().String()
+		}
+		pending := &pendingCommand{
+			connID:    connID,
+			agentID:   d.connStream.agentID,
+			operator:  operator,
+			stream:    spec.Stream,
+			acked:     make(chan struct{}),
+			output:    make(chan *commandOutput, outputBacklog),
+			result:    make(chan *commandResult, 1),
+			maxOutput: spec.maxOutputBytes(),
+		}
+		if _, exists := s.pending.LoadOrStore(d.commandID, pending); exists {
+			d.err = errCommandIDInUse
+			continue
+		}
+		d.pending = pending
+		issued = append(issued, d)
+		entries = append(entries, issuedEntry(d.connStream, d.commandID, spec, operator))
+	}
+
+	// Nothing is sent that could not be audited.
+	if err := s.audit.record(entries...); err != nil {
+		for _, d := range issued {
+			s.pending.Delete(d.commandID)
+			d.pending, d.err = nil, err
+		}
+		return dispatches
+	}
+
+	for _, d := range issued {
+		out := &pb.ResponseMessage{Body: &pb.ResponseMessage_Command{Command: spec.proto(d.commandID, operator)}}
+		if err := d.connStream.send(out); err != nil {
+			s.pending.Delete(d.commandID)
+			s.recordOutcome(auditFailed, d.commandID, d.pending, &commandResult{ExitCode: -1, Error: err.Error()})
+			d.pending, d.err = nil, fmt.Errorf("%w: %v", errSendFailed, err)
+			continue
+		}
+		s.logger.Log(d.connID + " | command | " + d.commandID + " | " + operator + " | " + spec.Message)
+	}
+	return dispatches
+}
+
+// cancelCommand asks the agent running commandID to kill it and reports the
+// command as cancelled to whoever is waiting on it. Whatever the agent then
+// sends back for the command is only logged.
+func (s *SysWatchServer) cancelCommand(commandID, operator string) error {
+	value, ok := s.pending.Load(commandID)
+	if !ok {
+		return errCommandNotFound
+	}
+	pending := value.(*pendingCommand)
+	connID := pending.connID
+
+	value, ok = s.clients.Load(connID)
+	if !ok {
+		return errConnectionNotFound
+	}
+	out := &pb.ResponseMessage{Body: &pb.ResponseMessage_CancelCommand{CancelCommand: &pb.CancelCommand{
+		CommandId:   commandID,
+		CancelledBy: operator,
+	}}}
+	if err := value.(*connectionStream).send(out); err != nil {
+		return err
+	}
+
+	// The command's result or the connection closing may have claimed it
+	// while the cancel was being sent.
+	if _, claimed := s.pending.LoadAndDelete(commandID); !claimed {
+		return errCommandNotFound
+	}
+	s.logger.Log(connID + " | cancel | " + commandID + " | " + operator)
+	if err := s.audit.record(&auditEntry{
+		Event:        auditCancelled,
+		Operator:     operator,
+		CommandID:    commandID,
+		ConnectionID: connID,
+		AgentID:      pending.agentID,
+	}); err != nil {
+		log.Printf("Failed to audit cancel of command %s: %v", commandID, err)
+	}
+	pending.result <- &commandResult{
+		CommandID: commandID,
+		ExitCode:  -1,
+		Cancelled: true,
+		Error:     "cancelled by " + operator,
+	}
+	return nil
+}
+
+// dropCommands stops tracking the commands dispatched to connID once the
+// connection has gone away and their replies can no longer arrive.
+func (s *SysWatchServer) dropCommands(connID string) {
+	s.pending.Range(func(key, value interface{}) bool {
+		if value.(*pendingCommand).connID != connID {
+			return true
+		}
+		// A result being delivered meanwhile may have claimed it first.
+		if value, claimed := s.pending.LoadAndDelete(key); claimed {
+			s.recordOutcome(auditLost, key.(string), value.(*pendingCommand), nil)
+		}
+		return true
+	})
+}
+
+// lookupCommand returns the pending command commandID if it was dispatched to connID.
+func (s *SysWatchServer) lookupCommand(connID, commandID string) (*pendingCommand, bool) {
+	value, ok := s.pending.Load(commandID)
+	if !ok {
+		return nil, false
+	}
+	pending := value.(*pendingCommand)
+	if pending.connID != connID {
+		log.Printf("Discarding message about command %s from unexpected connection ID %s", commandID, connID)
+		return nil, false
+	}
+	return pending, true
+}
+
+// acknowledgeCommand records that the agent received a command.
+func (s *SysWatchServer) acknowledgeCommand(connID string, ack *pb.Ack) {
+	if pending, ok := s.lookupCommand(connID, ack.GetCommandId()); ok {
+		pending.ackOnce.Do(func() { close(pending.acked) })
+	}
+}
+
+// completeCommand hands an agent's reply to whoever is waiting on it, along
+// with any output the agent sent ahead in chunks.
+func (s *SysWatchServer) completeCommand(connID string, result *pb.CommandResult) {
+	decoded := newCommandResult(result)
+	if result.GetOutputChunked() {
+		if pending, ok := s.lookupCommand(connID, result.GetCommandId()); ok && !pending.stream {
+			decoded.Stdout = pending.stdout.String()
+			decoded.Stderr = pending.stderr.String()
+			decoded.Truncated = decoded.Truncated || pending.outputTruncated
+		}
+	}
+	s.deliverResult(connID, decoded)
+}
+
+// rejectCommand reports a command the agent refused to run as its result.
+func (s *SysWatchServer) rejectCommand(connID string, rejected *pb.CommandRejected) {
+	s.deliverResult(connID, &commandResult{
+		CommandID: rejected.GetCommandId(),
+		ExitCode:  -1,
+		Error:     "rejected by agent: " + rejected.GetReason(),
+		Rejected:  rejected.GetReason(),
+	})
+}
+
+// denyCommand reports a command the agent policy forbids as its result.
+func (s *SysWatchServer) denyCommand(connID string, denial *pb.PolicyDenial) {
+	s.deliverResult(connID, &commandResult{
+		CommandID: denial.GetCommandId(),
+		ExitCode:  -1,
+		Error:     "denied by agent policy: " + denial.GetReason(),
+		Denied:    denial.GetReason(),
+	})
+}
+
+func (s *SysWatchServer) deliverResult(connID string, result *commandResult) {
+	if data, err := json.Marshal(result); err == nil {
+		s.logger.Log(connID + " | result | " + string(data))
+	}
+
+	pending, ok := s.lookupCommand(connID, result.CommandID)
+	if !ok {
+		return
+	}
+	// Only one of the result, a cancel or the connection closing claims the
+	// command.
+	if _, claimed := s.pending.LoadAndDelete(result.CommandID); !claimed {
+		return
+	}
+	s.recordOutcome(auditResult, result.CommandID, pending, result)
+	pending.result <- result
+}
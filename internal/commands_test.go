@@ -0,0 +1,58 @@
+package syswatch
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	pb "github.com/clwg/syswatch/proto"
+)
+
+func TestCommandOutcomeClaimedOnce(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.file.Close()
+	s := newTestServer(t, ServerConfig{Audit: audit})
+
+	const commands = 200
+	pending := make([]*pendingCommand, commands)
+	for i := range pending {
+		pending[i] = &pendingCommand{connID: "conn", result: make(chan *commandResult, 4)}
+		s.pending.Store(fmt.Sprint(i), pending[i])
+	}
+
+	// Results, some of them repeated, arrive while the connection is dropped.
+	var wg sync.WaitGroup
+	for n := 0; n < 4; n++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < commands; i++ {
+				s.completeCommand("conn", &pb.CommandResult{CommandId: fmt.Sprint(i)})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			s.dropCommands("conn")
+		}()
+	}
+	wg.Wait()
+
+	report := verifyAuditLog(t, filename)
+	outcomes := make(map[string]int)
+	for _, entry := range report.Entries {
+		outcomes[entry.CommandID]++
+	}
+	for i := 0; i < commands; i++ {
+		if n := outcomes[fmt.Sprint(i)]; n != 1 {
+			t.Errorf("command %d has %d outcomes, want 1", i, n)
+		}
+		if n := len(pending[i].result); n > 1 {
+			t.Errorf("command %d was sent %d results, want at most 1", i, n)
+		}
+	}
+}
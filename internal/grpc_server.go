@@ -1,80 +1,239 @@
 package syswatch
 
 import (
-	"context"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	logwriter "github.com/clwg/go-rotating-logger"
 	pb "github.com/clwg/syswatch/proto"
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type connectionStream struct {
-	stream pb.SysWatch_BidirectionalStreamPayloadServer
-	active bool
+	stream   pb.SysWatch_BidirectionalStreamPayloadServer
+	active   atomic.Bool // Cleared once a send to the stream fails
+	connID   string
+	agentID  string // Persistent agent ID presented by the client
+	identity string // Client certificate subject when using mutual TLS
+	sendMu   sync.Mutex
+	lastSeen atomic.Int64 // Unix nanoseconds of the last message received
+	evicted  chan struct{}
+	evictErr error // Why the connection was evicted, set before evicted is closed
 }
 
+// send serialises writes to the stream, which gRPC does not allow concurrently.
+func (c *connectionStream) send(msg *pb.ResponseMessage) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.stream.Send(msg)
+}
+
+// ServerConfig holds the settings used to initialize a SysWatchServer.
+type ServerConfig struct {
+	Logger            *logwriter.Logger
+	APITokens         APITokens        // Operators allowed to use the HTTP API
+	HeartbeatInterval time.Duration    // How often agents are expected to send a heartbeat
+	MissedHeartbeats  int              // Heartbeats an agent may miss before it is evicted
+	Templates         CommandTemplates // Named commands operators can dispatch
+	Audit             *AuditLog        // Where issued commands and their outcomes are recorded, if anywhere
+}
+
+// SysWatchServer implements the agent-facing SysWatch service. Agents may only
+// stream telemetry and receive commands over it; commands are issued solely by
+// authenticated operators through the HTTP API.
 type SysWatchServer struct {
 	pb.UnimplementedSysWatchServer
-	clients sync.Map
-	stopCh  chan struct{}
-	logger  *logwriter.Logger
+	clients           sync.Map
+	agents            sync.Map
+	pending           sync.Map
+	jobs              sync.Map
+	stopCh            chan struct{}
+	logger            *logwriter.Logger
+	apiTokens         APITokens
+	heartbeatInterval time.Duration
+	missedHeartbeats  int
+	templates         CommandTemplates
+	audit             *AuditLog
 }
 
-func InitializeSysWatchServer(logger *logwriter.Logger) *SysWatchServer {
-	return &SysWatchServer{
-		stopCh: make(chan struct{}),
-		logger: logger,
+func InitializeSysWatchServer(config ServerConfig) *SysWatchServer {
+	s := &SysWatchServer{
+		stopCh:            make(chan struct{}),
+		logger:            config.Logger,
+		apiTokens:         config.APITokens,
+		heartbeatInterval: config.HeartbeatInterval,
+		missedHeartbeats:  config.MissedHeartbeats,
+		templates:         config.Templates,
+		audit:             config.Audit,
 	}
-}
-
-func (s *SysWatchServer) GenerateUUID(context.Context, *pb.Empty) (*pb.UUIDResponse, error) {
-	uuid := uuid.New().String()
-	return &pb.UUIDResponse{Uuid: uuid}, nil
+	if s.heartbeatInterval > 0 && s.missedHeartbeats > 0 {
+		go s.evictDeadConnections()
+	}
+	return s
 }
 
 func (s *SysWatchServer) BidirectionalStreamPayload(stream pb.SysWatch_BidirectionalStreamPayloadServer) error {
-	var connID string
+	connStream, err := s.registerStream(stream)
+	if err != nil {
+		log.Printf("Rejected client registration: %v", err)
+		return err
+	}
+	connID := connStream.connID
+	defer func() {
+		s.clients.Delete(connID)
+		s.detachAgent(connStream.agentID, connID)
+		s.dropCommands(connID)
+		log.Printf("Client disconnected with connection ID: %s", connID)
+	}()
 
+	recvErr := make(chan error, 1)
+	go func() { recvErr <- s.receiveMessages(connStream) }()
+
+	select {
+	case err := <-recvErr:
+		return err
+	case <-connStream.evicted:
+		return connStream.evictErr
+	}
+}
+
+// receiveMessages handles everything an agent sends until the stream fails.
+func (s *SysWatchServer) receiveMessages(connStream *connectionStream) error {
+	connID := connStream.connID
 	for {
-		in, err := stream.Recv()
+		in, err := connStream.stream.Recv()
 		if err != nil {
 			log.Printf("Failed to receive a message: %v", err)
-			break
+			return nil
 		}
 
-		if connID == "" {
-			connID = in.GetConnectionId()
-			s.clients.Store(connID, &connectionStream{stream: stream, active: true})
-			log.Printf("Server registered new client with connection ID: %s", connID)
+		if in.GetConnectionId() != connID {
+			log.Printf("Connection ID %s sent a message for connection ID %q, closing stream", connID, in.GetConnectionId())
+			return status.Errorf(codes.PermissionDenied, "connection ID %q is not bound to this stream", in.GetConnectionId())
 		}
 
-		source := in.GetSource()
-		logData := connID + " | " + source + " | " + in.GetPayload()
+		connStream.lastSeen.Store(time.Now().UnixNano())
+		s.touchAgent(connStream.agentID, in.GetRegistration())
 
-		s.logger.Log(logData)
+		switch body := in.GetBody().(type) {
+		case *pb.RequestMessage_Registration:
+			log.Printf("Connection ID %s registered as host %s", connID, body.Registration.GetHostname())
+		case *pb.RequestMessage_Heartbeat:
+			reply := &pb.ResponseMessage{Body: &pb.ResponseMessage_Heartbeat{
+				Heartbeat: &pb.Heartbeat{SentUnixNano: time.Now().UnixNano()},
+			}}
+			if err := connStream.send(reply); err != nil {
+				log.Printf("Failed to answer heartbeat from connection ID %s: %v", connID, err)
+			}
+		case *pb.RequestMessage_LogLine:
+			s.logger.Log(connID + " | " + body.LogLine.GetSource() + " | " + body.LogLine.GetLine())
+		case *pb.RequestMessage_Ack:
+			s.acknowledgeCommand(connID, body.Ack)
+		case *pb.RequestMessage_CommandOutput:
+			s.forwardOutput(connID, body.CommandOutput)
+		case *pb.RequestMessage_CommandResult:
+			s.completeCommand(connID, body.CommandResult)
+		case *pb.RequestMessage_CommandRejected:
+			s.rejectCommand(connID, body.CommandRejected)
+		case *pb.RequestMessage_PolicyDenial:
+			s.denyCommand(connID, body.PolicyDenial)
+		default:
+			log.Printf("Ignoring message of unknown type from connection ID %s", connID)
+		}
 	}
+}
+
+// registerStream assigns a new connection ID to stream, registers it against
+// the agent ID the client presented and tells the client which connection ID
+// it was given. Registrations never replace a live entry in the clients map.
+func (s *SysWatchServer) registerStream(stream pb.SysWatch_BidirectionalStreamPayloadServer) (*connectionStream, error) {
+	connID := uuid.New().String()
+	identity := peerIdentity(stream.Context())
+	agentID := agentIDFromContext(stream.Context())
+	switch {
+	case identity != "" && agentID == "":
+		agentID = identity
+	case identity != "" && agentID != identity:
+		// With mutual TLS the certificate names the agent, so one agent cannot
+		// claim another's ID.
+		return nil, status.Errorf(codes.PermissionDenied, "agent ID %s does not match certificate %q", agentID, identity)
+	case agentID == "":
+		agentID = connID // Clients without a persistent ID are tracked per connection
+	}
+	connStream := &connectionStream{
+		stream:   stream,
+		connID:   connID,
+		agentID:  agentID,
+		identity: identity,
+		evicted:  make(chan struct{}),
+	}
+	connStream.active.Store(true)
+	connStream.lastSeen.Store(time.Now().UnixNano())
+
+	// Hold the send lock so no command can overtake the handshake.
+	connStream.sendMu.Lock()
+	defer connStream.sendMu.Unlock()
 
-	s.clients.Delete(connID)
-	log.Printf("Client disconnected with connection ID: %s", connID)
-	return nil
+	if _, loaded := s.clients.LoadOrStore(connID, connStream); loaded {
+		return nil, status.Errorf(codes.AlreadyExists, "connection ID %s is already registered", connID)
+	}
+	if err := s.attachAgent(agentID, identity, connID); err != nil {
+		s.clients.Delete(connID)
+		return nil, err
+	}
+
+	handshake := &pb.ResponseMessage{Body: &pb.ResponseMessage_Handshake{
+		Handshake: &pb.Handshake{ConnectionId: connID},
+	}}
+	if err := stream.Send(handshake); err != nil {
+		s.clients.Delete(connID)
+		s.detachAgent(agentID, connID)
+		return nil, err
+	}
+
+	log.Printf("Server registered new client with connection ID: %s (agent %s, identity %q)", connID, agentID, identity)
+	return connStream, nil
 }
 
-func (s *SysWatchServer) directMessage(payload, senderID string) {
-	s.clients.Range(func(key, value interface{}) bool {
-		connID := key.(string)
-		connStream := value.(*connectionStream)
+// evictDeadConnections closes connections that have not been heard from for
+// missedHeartbeats heartbeat intervals.
+func (s *SysWatchServer) evictDeadConnections() {
+	ticker := time.NewTicker(s.heartbeatInterval)
+	defer ticker.Stop()
 
-		if connID != senderID && connStream.active {
-			out := &pb.ResponseMessage{Payload: payload}
-			if err := connStream.stream.Send(out); err != nil {
-				log.Printf("Failed to send a message to connection ID %s: %v", connID, err)
-				connStream.active = false // Mark as inactive on failure
-			}
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stopCh:
+			return
 		}
-		return true
-	})
+
+		cutoff := time.Now().Add(-s.heartbeatInterval * time.Duration(s.missedHeartbeats)).UnixNano()
+		s.clients.Range(func(key, value interface{}) bool {
+			connStream := value.(*connectionStream)
+			if connStream.lastSeen.Load() < cutoff {
+				log.Printf("Evicting connection ID %s after %d missed heartbeats", connStream.connID, s.missedHeartbeats)
+				s.evictConnection(connStream.connID, status.Errorf(codes.Unavailable, "connection ID %s missed %d heartbeats", connStream.connID, s.missedHeartbeats))
+			}
+			return true
+		})
+	}
+}
+
+// evictConnection closes the stream of connID, which ends with err, unless it
+// has already gone away.
+func (s *SysWatchServer) evictConnection(connID string, err error) {
+	value, ok := s.clients.LoadAndDelete(connID)
+	if !ok {
+		return
+	}
+	connStream := value.(*connectionStream)
+	connStream.evictErr = err
+	close(connStream.evicted)
 }
 
 func (s *SysWatchServer) getActiveConnections() []string {
@@ -82,7 +241,7 @@ func (s *SysWatchServer) getActiveConnections() []string {
 	s.clients.Range(func(key, value interface{}) bool {
 		connID := key.(string)
 		connStream := value.(*connectionStream)
-		if connStream.active {
+		if connStream.active.Load() {
 			connections = append(connections, connID)
 		}
 		return true
@@ -0,0 +1,127 @@
+package syswatch
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+
+	pb "github.com/clwg/syswatch/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// streamContext is the context of a stream opened by a client presenting
+// agentID and, when identity is set, a verified certificate for it.
+func streamContext(agentID, identity string) context.Context {
+	ctx := context.Background()
+	if agentID != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(agentIDMetadataKey, agentID))
+	}
+	if identity != "" {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: identity}}
+		ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		}})
+	}
+	return ctx
+}
+
+func TestRegisterStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		agentID     string
+		identity    string
+		wantAgentID string // Empty when the agent is tracked by its connection ID
+		wantCode    codes.Code
+	}{
+		{"persistent agent ID", "agent-1", "", "agent-1", codes.OK},
+		{"no agent ID", "", "", "", codes.OK},
+		{"certificate", "web-01", "web-01", "web-01", codes.OK},
+		{"certificate without agent ID", "", "web-01", "web-01", codes.OK},
+		{"agent ID not matching certificate", "web-02", "web-01", "", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, ServerConfig{})
+			stream := &fakeStream{ctx: streamContext(tt.agentID, tt.identity)}
+			connStream, err := s.registerStream(stream)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("registerStream() = %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil {
+				if len(stream.sent) != 0 {
+					t.Errorf("sent %v to a rejected stream", stream.sent)
+				}
+				if len(s.getActiveConnections()) != 0 {
+					t.Error("a rejected stream is registered")
+				}
+				return
+			}
+
+			// The handshake carries the connection ID the server assigned.
+			if len(stream.sent) != 1 || stream.sent[0].GetHandshake().GetConnectionId() != connStream.connID || connStream.connID == "" {
+				t.Fatalf("sent %v, want a handshake with connection ID %q", stream.sent, connStream.connID)
+			}
+			wantAgentID := tt.wantAgentID
+			if wantAgentID == "" {
+				wantAgentID = connStream.connID
+			}
+			if connStream.agentID != wantAgentID || connStream.identity != tt.identity {
+				t.Errorf("registered agent %q with identity %q, want %q and %q", connStream.agentID, connStream.identity, wantAgentID, tt.identity)
+			}
+			if conn := s.resolveConnection(wantAgentID); conn != connStream.connID {
+				t.Errorf("agent %s resolves to %s, want %s", wantAgentID, conn, connStream.connID)
+			}
+		})
+	}
+}
+
+func TestRegisterStreamHandshakeFails(t *testing.T) {
+	s := newTestServer(t, ServerConfig{})
+	stream := &fakeStream{ctx: streamContext("agent-1", ""), sendErr: errors.New("transport is closing")}
+	if _, err := s.registerStream(stream); err == nil {
+		t.Fatal("registerStream() succeeded without a handshake")
+	}
+	if len(s.getActiveConnections()) != 0 || s.resolveConnection("agent-1") != "agent-1" {
+		t.Error("a stream whose handshake failed is still registered")
+	}
+}
+
+func TestReceiveMessagesConnectionIDMismatch(t *testing.T) {
+	s := newTestServer(t, ServerConfig{})
+	first, err := s.registerStream(&fakeStream{ctx: streamContext("agent-1", "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := &fakeStream{ctx: streamContext("agent-2", "")}
+	second, err := s.registerStream(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	heartbeat := &pb.RequestMessage_Heartbeat{Heartbeat: &pb.Heartbeat{}}
+	stream.recv = []*pb.RequestMessage{
+		{ConnectionId: second.connID, Body: heartbeat},
+		// The stream claims to be the other agent's connection.
+		{ConnectionId: first.connID, Body: heartbeat},
+		{ConnectionId: second.connID, Body: heartbeat},
+	}
+	err = s.receiveMessages(second)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("receiveMessages() = %v, want code PermissionDenied", err)
+	}
+	// The handshake and the reply to the first heartbeat, but nothing after
+	// the mismatch.
+	if len(stream.sent) != 2 {
+		t.Errorf("sent %d messages, want 2", len(stream.sent))
+	}
+	if len(stream.recv) != 1 {
+		t.Errorf("%d messages left unread, want 1", len(stream.recv))
+	}
+}
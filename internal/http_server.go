@@ -1,32 +1,75 @@
 package syswatch
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-
-	pb "github.com/clwg/syswatch/proto"
+	"time"
 )
 
-func StartHTTPServer(s *SysWatchServer, port int) {
-	http.HandleFunc("/connections", s.listConnections)
-	http.HandleFunc("/send", s.apiSendMessage)
-	http.HandleFunc("/broadcast", s.apiBroadcastMessage)
+// defaultReplyTimeout bounds how long to wait for a reply when neither a wait
+// timeout nor a longer command timeout is given.
+const defaultReplyTimeout = 30 * time.Second
+
+// replyGrace is how much longer than a command's own timeout to wait for its
+// reply, covering delivery and the agent reporting back.
+const replyGrace = 5 * time.Second
+
+// StartHTTPServer serves the operator API on addr, over TLS when tlsConfig is
+// set.
+func StartHTTPServer(s *SysWatchServer, addr string, tlsConfig *tls.Config) {
+	if len(s.apiTokens) == 0 {
+		log.Println("No API tokens configured, every HTTP API request will be rejected")
+	}
 
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
+	http.HandleFunc("/connections", s.requireOperator(s.listConnections))
+	http.HandleFunc("/agents", s.requireOperator(s.listAgents))
+	http.HandleFunc("/send", s.requireOperator(s.apiSendMessage))
+	http.HandleFunc("/broadcast", s.requireOperator(s.apiBroadcastMessage))
+	http.HandleFunc("/cancel", s.requireOperator(s.apiCancelCommand))
+	http.HandleFunc("/templates", s.requireOperator(s.listTemplates))
+	http.HandleFunc("/jobs", s.requireOperator(s.apiJobStatus))
+	http.HandleFunc("/audit", s.requireOperator(s.apiAudit))
+
+	server := &http.Server{Addr: addr, TLSConfig: tlsConfig}
+	if tlsConfig != nil {
+		log.Printf("HTTP API listening at https://%s", addr)
+		log.Fatal(server.ListenAndServeTLS("", ""))
+	}
+	log.Printf("HTTP API listening at http://%s", addr)
+	log.Fatal(server.ListenAndServe())
 }
 
-// This is the HTTP handler that uses getActiveConnections
+// listConnections reports the agent behind each active connection.
 func (s *SysWatchServer) listConnections(w http.ResponseWriter, r *http.Request) {
-	connections := s.getActiveConnections()
+	connections := []agentSnapshot{}
+	for _, agent := range s.getAgents() {
+		if agent.Connected {
+			connections = append(connections, agent)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(connections)
 }
 
+// listAgents reports every agent seen since the server started, connected or not.
+func (s *SysWatchServer) listAgents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.getAgents())
+}
+
 func (s *SysWatchServer) apiSendMessage(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ID      string `json:"id"`
-		Message string `json:"message"`
+		ID          string `json:"id"`
+		Selector    string `json:"selector"` // labels such as env=prod,role=db
+		Hostname    string `json:"hostname"` // hostname glob such as web-*
+		Wait        bool   `json:"wait"`
+		WaitTimeout int    `json:"wait_timeout"` // seconds to wait for the reply
+		commandSpec
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -34,42 +77,215 @@ func (s *SysWatchServer) apiSendMessage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if req.ID == "" || req.Message == "" {
-		http.Error(w, "Missing id or message in request body", http.StatusBadRequest)
+	targeted := req.Selector != "" || req.Hostname != ""
+	if req.ID == "" && !targeted && req.signed() {
+		req.ID = req.Target
+	}
+	if req.ID == "" && !targeted {
+		http.Error(w, "Missing id or selector in request body", http.StatusBadRequest)
+		return
+	}
+	if req.ID != "" && targeted {
+		http.Error(w, "Specify either id or selector/hostname, not both", http.StatusBadRequest)
+		return
+	}
+	if err := s.resolveCommand(&req.commandSpec); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid command: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid command: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Stream && targeted {
+		http.Error(w, "Streaming requires a single id", http.StatusBadRequest)
+		return
+	}
+	if req.signed() && targeted {
+		http.Error(w, "A signed command is for a single agent, its target", http.StatusBadRequest)
+		return
+	}
+	if targeted {
+		s.sendToSelector(w, r, req.Selector, req.Hostname, req.commandSpec, req.Wait, req.WaitTimeout)
 		return
 	}
 
-	value, ok := s.clients.Load(req.ID)
-	if !ok {
+	pending, commandID, err := s.dispatchCommand(s.resolveConnection(req.ID), req.commandSpec, operatorFromContext(r.Context()))
+	if errors.Is(err, errConnectionNotFound) {
 		http.Error(w, "Connection ID not found", http.StatusNotFound)
 		return
 	}
+	if errors.Is(err, errCommandIDInUse) {
+		http.Error(w, "Command ID already in use", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
 
-	connStream := value.(*connectionStream)
-	out := &pb.ResponseMessage{Payload: req.Message}
+	timeout := req.replyTimeout()
+	if req.WaitTimeout > 0 {
+		timeout = time.Duration(req.WaitTimeout) * time.Second
+	}
+	if req.Stream {
+		s.streamCommand(w, r, pending, commandID, timeout)
+		return
+	}
 
-	if err := connStream.stream.Send(out); err != nil {
-		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+	response := struct {
+		Status    string `json:"status"`
+		Message   string `json:"message"`
+		CommandID string `json:"command_id"`
+		*commandResult
+	}{
+		Status:    "success",
+		Message:   "Message sent successfully",
+		CommandID: commandID,
+	}
+	statusCode := http.StatusOK
+
+	if req.Wait {
+		select {
+		case result := <-pending.result:
+			response.Message = "Command completed"
+			response.commandResult = result
+			switch {
+			case result.Denied != "":
+				response.Status = "denied"
+				response.Message = "Agent policy denied the command"
+				statusCode = http.StatusForbidden
+			case result.Rejected != "":
+				response.Status = "rejected"
+				response.Message = "Agent rejected the command"
+				statusCode = http.StatusServiceUnavailable
+			case result.Cancelled:
+				response.Status = "cancelled"
+				response.Message = "Command was cancelled"
+			case result.Error != "":
+				// The agent could not run the command at all, so there is
+				// no exit code to report.
+				response.Status = "failed"
+				response.Message = "Agent failed to run the command: " + result.Error
+				statusCode = http.StatusBadGateway
+			}
+		case <-time.After(timeout):
+			response.Status = "timeout"
+			response.Message = "Timed out waiting for command reply"
+			statusCode = http.StatusGatewayTimeout
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// sendToSelector dispatches a command to every connected agent matching the
+// selector and reports which agents matched alongside the job results.
+func (s *SysWatchServer) sendToSelector(w http.ResponseWriter, r *http.Request, labels, hostname string, spec commandSpec, wait bool, timeoutSecs int) {
+	sel, err := parseSelector(labels, hostname)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid selector: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	matched := s.resolveSelector(sel)
+	if len(matched) == 0 {
+		http.Error(w, "No connected agents matched the selector", http.StatusNotFound)
 		return
 	}
 
+	connIDs := make([]string, len(matched))
+	for i, agent := range matched {
+		connIDs[i] = agent.ConnectionID
+	}
+
+	timeout := spec.replyTimeout()
+	if timeoutSecs > 0 {
+		timeout = time.Duration(timeoutSecs) * time.Second
+	}
+
+	job := s.startJob(connIDs, spec, operatorFromContext(r.Context()), timeout)
+	if wait {
+		select {
+		case <-job.done:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
 	response := struct {
-		Status  string `json:"status"`
-		Message string `json:"message"`
+		Matched []matchedAgent `json:"matched"`
+		jobSnapshot
 	}{
-		Status:  "success",
-		Message: "Message sent successfully",
+		Matched:     matched,
+		jobSnapshot: job.snapshot(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		log.Printf("Failed to encode response: %v", err)
 	}
 }
 
 func (s *SysWatchServer) apiBroadcastMessage(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Message string `json:"message"`
+		Wait        bool `json:"wait"`
+		WaitTimeout int  `json:"wait_timeout"` // seconds to wait for each endpoint's reply
+		commandSpec
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.resolveCommand(&req.commandSpec); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid command: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid command: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Stream {
+		http.Error(w, "Streaming requires a single id, use /send", http.StatusBadRequest)
+		return
+	}
+	if req.signed() {
+		http.Error(w, "A signed command is for a single agent, use /send", http.StatusBadRequest)
+		return
+	}
+
+	timeout := req.replyTimeout()
+	if req.WaitTimeout > 0 {
+		timeout = time.Duration(req.WaitTimeout) * time.Second
+	}
+
+	job := s.startJob(s.getActiveConnections(), req.commandSpec, operatorFromContext(r.Context()), timeout)
+	if req.Wait {
+		select {
+		case <-job.done:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job.snapshot()); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// apiCancelCommand kills a command that is still running on its agent.
+func (s *SysWatchServer) apiCancelCommand(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CommandID string `json:"command_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -77,24 +293,62 @@ func (s *SysWatchServer) apiBroadcastMessage(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if req.Message == "" {
-		http.Error(w, "Missing message in request body", http.StatusBadRequest)
+	if req.CommandID == "" {
+		http.Error(w, "Missing command_id in request body", http.StatusBadRequest)
 		return
 	}
 
-	// This is terrible logic
-	s.directMessage(req.Message, "") // Pass empty senderID to broadcast to all clients
+	err := s.cancelCommand(req.CommandID, operatorFromContext(r.Context()))
+	if errors.Is(err, errCommandNotFound) || errors.Is(err, errConnectionNotFound) {
+		http.Error(w, "Command ID not found or already finished", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to send cancel", http.StatusInternalServerError)
+		return
+	}
 
 	response := struct {
-		Status  string `json:"status"`
-		Message string `json:"message"`
+		Status    string `json:"status"`
+		Message   string `json:"message"`
+		CommandID string `json:"command_id"`
 	}{
-		Status:  "success",
-		Message: "Broadcast message sent successfully",
+		Status:    "success",
+		Message:   "Cancel sent",
+		CommandID: req.CommandID,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// apiJobStatus reports the per-endpoint results of a broadcast job, optionally
+// blocking until every endpoint has replied or timed out.
+func (s *SysWatchServer) apiJobStatus(w http.ResponseWriter, r *http.Request) {
+	jobID := r.URL.Query().Get("id")
+	if jobID == "" {
+		http.Error(w, "Missing id query parameter", http.StatusBadRequest)
+		return
+	}
+
+	job, ok := s.getJob(jobID)
+	if !ok {
+		http.Error(w, "Job ID not found", http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("wait") == "true" {
+		select {
+		case <-job.done:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job.snapshot()); err != nil {
+		log.Printf("Failed to encode response: %v", err)
 	}
 }
@@ -0,0 +1,89 @@
+package syswatch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/clwg/syswatch/proto"
+)
+
+// sendAndReply posts body to /send for the connection conn and answers the
+// command it dispatches with reply, returning the response.
+func sendAndReply(t *testing.T, s *SysWatchServer, body string, reply func(commandID string)) *httptest.ResponseRecorder {
+	t.Helper()
+	stream := &fakeStream{}
+	addTestConnection(s, "conn", stream)
+
+	req := httptest.NewRequest(http.MethodPost, "/send", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), operatorContextKey{}, "alice"))
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.apiSendMessage(rec, req)
+	}()
+
+	var commandID string
+	for deadline := time.Now().Add(5 * time.Second); commandID == "" && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		stream.mu.Lock()
+		if len(stream.sent) > 0 {
+			commandID = stream.sent[0].GetCommand().GetCommandId()
+		}
+		stream.mu.Unlock()
+	}
+	if commandID == "" {
+		t.Fatal("no command was dispatched")
+	}
+	reply(commandID)
+	<-done
+	return rec
+}
+
+func TestSendWaitStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		result      *pb.CommandResult
+		wantCode    int
+		wantStatus  string
+		wantMessage string
+	}{
+		{"exit code", &pb.CommandResult{ExitCode: 2}, http.StatusOK, "success", "Command completed"},
+		{"timed out", &pb.CommandResult{ExitCode: -1, Signal: "terminated", TimedOut: true}, http.StatusOK, "success", "Command completed"},
+		{"cancelled", &pb.CommandResult{ExitCode: -1, Signal: "terminated", Cancelled: true}, http.StatusOK, "cancelled", "Command was cancelled"},
+		{
+			"never started",
+			&pb.CommandResult{ExitCode: -1, Error: `exec: "nmap": executable file not found in $PATH`},
+			http.StatusBadGateway, "failed", `Agent failed to run the command: exec: "nmap": executable file not found in $PATH`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, ServerConfig{})
+			rec := sendAndReply(t, s, `{"id":"conn","message":"nmap","wait":true}`, func(commandID string) {
+				tt.result.CommandId = commandID
+				s.completeCommand("conn", tt.result)
+			})
+
+			var response struct {
+				Status   string `json:"status"`
+				Message  string `json:"message"`
+				ExitCode int    `json:"exit_code"`
+				Error    string `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("response %q: %v", rec.Body.String(), err)
+			}
+			if rec.Code != tt.wantCode || response.Status != tt.wantStatus || response.Message != tt.wantMessage {
+				t.Errorf("response %d %q %q, want %d %q %q", rec.Code, response.Status, response.Message, tt.wantCode, tt.wantStatus, tt.wantMessage)
+			}
+			if response.ExitCode != int(tt.result.ExitCode) || response.Error != tt.result.Error {
+				t.Errorf("exit code %d, error %q; want %d, %q", response.ExitCode, response.Error, tt.result.ExitCode, tt.result.Error)
+			}
+		})
+	}
+}
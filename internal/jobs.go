@@ -0,0 +1,198 @@
+package syswatch
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// jobRetention is how long finished broadcast jobs remain available for polling.
+const jobRetention = time.Hour
+
+// Per-endpoint states of a broadcast job.
+const (
+	endpointPending   = "pending"
+	endpointDelivered = "delivered"
+	endpointAcked     = "acknowledged"
+	endpointCompleted = "completed"
+	endpointCancelled = "cancelled"
+	endpointRejected  = "rejected"
+	endpointDenied    = "denied"
+	endpointFailed    = "failed"
+	endpointTimedOut  = "timed_out"
+)
+
+// endpointResult is the outcome of a broadcast job on a single connection.
+type endpointResult struct {
+	Status    string         `json:"status"`
+	CommandID string         `json:"command_id,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Result    *commandResult `json:"result,omitempty"`
+}
+
+// broadcastJob fans a command out to many connections and collects each reply.
+type broadcastJob struct {
+	id        string
+	command   string
+	operator  string
+	createdAt time.Time
+
+	mu         sync.Mutex
+	finishedAt time.Time
+	results    map[string]*endpointResult
+	done       chan struct{}
+}
+
+// jobSnapshot is the JSON view of a broadcast job.
+type jobSnapshot struct {
+	JobID      string                     `json:"job_id"`
+	Command    string                     `json:"command"`
+	IssuedBy   string                     `json:"issued_by"`
+	Status     string                     `json:"status"`
+	CreatedAt  time.Time                  `json:"created_at"`
+	FinishedAt *time.Time                 `json:"finished_at,omitempty"`
+	Results    map[string]*endpointResult `json:"results"`
+}
+
+func newBroadcastJob(command, operator string) *broadcastJob {
+	return &broadcastJob{
+		id:        uuid.New().String(),
+		command:   command,
+		operator:  operator,
+		createdAt: time.Now(),
+		results:   make(map[string]*endpointResult),
+		done:      make(chan struct{}),
+	}
+}
+
+func (j *broadcastJob) setResult(connID string, result *endpointResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.results[connID] = result
+}
+
+func (j *broadcastJob) snapshot() jobSnapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	snap := jobSnapshot{
+		JobID:     j.id,
+		Command:   j.command,
+		IssuedBy:  j.operator,
+		Status:    "running",
+		CreatedAt: j.createdAt,
+		Results:   make(map[string]*endpointResult, len(j.results)),
+	}
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		snap.Status = "finished"
+		snap.FinishedAt = &finishedAt
+	}
+	for connID, result := range j.results {
+		copied := *result
+		snap.Results[connID] = &copied
+	}
+	return snap
+}
+
+// startJob dispatches spec to each of connIDs and collects replies in the
+// background until each one arrives or timeout elapses.
+func (s *SysWatchServer) startJob(connIDs []string, spec commandSpec, operator string, timeout time.Duration) *broadcastJob {
+	s.pruneJobs()
+
+	job := newBroadcastJob(spec.Message, operator)
+	s.jobs.Store(job.id, job)
+
+	for _, connID := range connIDs {
+		job.setResult(connID, &endpointResult{Status: endpointPending})
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range s.dispatchCommands(connIDs, spec, operator) {
+		connID, commandID, pending := d.connID, d.commandID, d.pending
+		if d.err != nil {
+			log.Printf("Failed to send a message to connection ID %s: %v", connID, d.err)
+			if errors.Is(d.err, errSendFailed) {
+				d.connStream.active.Store(false)
+			}
+			job.setResult(connID, &endpointResult{Status: endpointFailed, CommandID: commandID, Error: d.err.Error()})
+			continue
+		}
+		job.setResult(connID, &endpointResult{Status: endpointDelivered, CommandID: commandID})
+
+		wg.Add(1)
+		go func(connID, commandID string) {
+			defer wg.Done()
+
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+
+			acked := pending.acked
+			for {
+				select {
+				case <-acked:
+					job.setResult(connID, &endpointResult{Status: endpointAcked, CommandID: commandID})
+					acked = nil
+				case result := <-pending.result:
+					job.setResult(connID, &endpointResult{Status: resultState(result), CommandID: commandID, Result: result})
+					return
+				case <-timer.C:
+					job.setResult(connID, &endpointResult{Status: endpointTimedOut, CommandID: commandID})
+					return
+				}
+			}
+		}(connID, commandID)
+	}
+
+	go func() {
+		wg.Wait()
+		job.mu.Lock()
+		job.finishedAt = time.Now()
+		job.mu.Unlock()
+		close(job.done)
+	}()
+
+	return job
+}
+
+// resultState is the endpoint state a command's result leaves it in.
+func resultState(result *commandResult) string {
+	switch {
+	case result.Denied != "":
+		return endpointDenied
+	case result.Rejected != "":
+		return endpointRejected
+	case result.Cancelled:
+		return endpointCancelled
+	case result.Error != "":
+		return endpointFailed
+	default:
+		return endpointCompleted
+	}
+}
+
+func (s *SysWatchServer) getJob(jobID string) (*broadcastJob, bool) {
+	value, ok := s.jobs.Load(jobID)
+	if !ok {
+		return nil, false
+	}
+	return value.(*broadcastJob), true
+}
+
+// pruneJobs forgets finished jobs older than jobRetention.
+func (s *SysWatchServer) pruneJobs() {
+	cutoff := time.Now().Add(-jobRetention)
+	s.jobs.Range(func(key, value interface{}) bool {
+		job := value.(*broadcastJob)
+		job.mu.Lock()
+		expired := !job.finishedAt.IsZero() && job.finishedAt.Before(cutoff)
+		job.mu.Unlock()
+		if expired {
+			s.jobs.Delete(key)
+		}
+		return true
+	})
+}
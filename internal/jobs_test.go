@@ -0,0 +1,150 @@
+package syswatch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/clwg/syswatch/proto"
+)
+
+// fakeStream is the server side of an agent's stream. Sends are recorded, or
+// fail with sendErr, and recv is received in turn followed by io.EOF.
+type fakeStream struct {
+	pb.SysWatch_BidirectionalStreamPayloadServer
+	ctx     context.Context
+	sendErr error
+	recv    []*pb.RequestMessage
+
+	mu   sync.Mutex
+	sent []*pb.ResponseMessage
+}
+
+func (f *fakeStream) Recv() (*pb.RequestMessage, error) {
+	if len(f.recv) == 0 {
+		return nil, io.EOF
+	}
+	msg := f.recv[0]
+	f.recv = f.recv[1:]
+	return msg, nil
+}
+
+func (f *fakeStream) Context() context.Context {
+	if f.ctx == nil {
+		return context.Background()
+	}
+	return f.ctx
+}
+
+func (f *fakeStream) Send(msg *pb.ResponseMessage) error {
+	if f.sendErr != nil {
+		return f.sendErr
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// addTestConnection registers a connection whose stream is stream.
+func addTestConnection(s *SysWatchServer, connID string, stream *fakeStream) *connectionStream {
+	connStream := &connectionStream{stream: stream, connID: connID, agentID: connID, evicted: make(chan struct{})}
+	connStream.active.Store(true)
+	s.clients.Store(connID, connStream)
+	return connStream
+}
+
+func TestStartJob(t *testing.T) {
+	s := newTestServer(t, ServerConfig{})
+	addTestConnection(s, "done", &fakeStream{})
+	addTestConnection(s, "acked", &fakeStream{})
+	broken := addTestConnection(s, "broken", &fakeStream{sendErr: errors.New("transport is closing")})
+
+	job := s.startJob([]string{"done", "acked", "broken", "gone"}, commandSpec{Message: "uptime"}, "alice", 200*time.Millisecond)
+	snap := job.snapshot()
+	if snap.Status != "running" || snap.Command != "uptime" || snap.IssuedBy != "alice" {
+		t.Errorf("job = %s, %q by %s; want a running uptime job by alice", snap.Status, snap.Command, snap.IssuedBy)
+	}
+
+	s.acknowledgeCommand("done", &pb.Ack{CommandId: snap.Results["done"].CommandID})
+	s.acknowledgeCommand("acked", &pb.Ack{CommandId: snap.Results["acked"].CommandID})
+	s.completeCommand("done", &pb.CommandResult{CommandId: snap.Results["done"].CommandID, Stdout: []byte("up"), ExitCode: 0})
+
+	select {
+	case <-job.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not finish")
+	}
+	snap = job.snapshot()
+	if snap.Status != "finished" || snap.FinishedAt == nil {
+		t.Errorf("job = %s, finished at %v; want finished", snap.Status, snap.FinishedAt)
+	}
+
+	want := map[string]string{
+		"done":   endpointCompleted,
+		"acked":  endpointTimedOut,
+		"broken": endpointFailed,
+		"gone":   endpointFailed,
+	}
+	for connID, status := range want {
+		if got := snap.Results[connID].Status; got != status {
+			t.Errorf("%s is %s, want %s", connID, got, status)
+		}
+	}
+	if result := snap.Results["done"].Result; result == nil || result.Stdout != "up" {
+		t.Errorf("result of done = %+v, want its output", result)
+	}
+
+	// Only a connection that could not be written to stops receiving broadcasts.
+	if broken.active.Load() {
+		t.Error("broken connection is still active")
+	}
+	if active := s.getActiveConnections(); len(active) != 2 {
+		t.Errorf("active connections = %v, want done and acked", active)
+	}
+}
+
+func TestResultState(t *testing.T) {
+	tests := []struct {
+		result *commandResult
+		want   string
+	}{
+		{&commandResult{ExitCode: 1}, endpointCompleted},
+		{&commandResult{ExitCode: -1, Cancelled: true}, endpointCancelled},
+		{&commandResult{ExitCode: -1, Cancelled: true, Error: "cancelled by alice"}, endpointCancelled},
+		{&commandResult{ExitCode: -1, Error: "exec: \"nmap\": executable file not found in $PATH"}, endpointFailed},
+		{&commandResult{ExitCode: -1, Rejected: "busy"}, endpointRejected},
+		{&commandResult{ExitCode: -1, Denied: "not allowed"}, endpointDenied},
+	}
+	for _, tt := range tests {
+		if got := resultState(tt.result); got != tt.want {
+			t.Errorf("resultState(%+v) = %s, want %s", tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestPruneJobs(t *testing.T) {
+	s := newTestServer(t, ServerConfig{})
+	jobs := map[string]time.Time{
+		"running":  {},
+		"recent":   time.Now().Add(-time.Minute),
+		"expired":  time.Now().Add(-jobRetention - time.Minute),
+		"boundary": time.Now().Add(-jobRetention + time.Minute),
+	}
+	for id, finishedAt := range jobs {
+		job := newBroadcastJob("uptime", "alice")
+		job.id, job.finishedAt = id, finishedAt
+		s.jobs.Store(id, job)
+	}
+
+	s.pruneJobs()
+	for id := range jobs {
+		_, kept := s.getJob(id)
+		if kept == (id == "expired") {
+			t.Errorf("job %s kept %v", id, kept)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package syswatch
+
+import (
+	"errors"
+	"path"
+
+	"github.com/clwg/syswatch/utils"
+)
+
+// agentSelector picks agents by their registered labels and hostname.
+type agentSelector struct {
+	labels   map[string]string
+	hostname string // Glob matched against the registered hostname
+}
+
+// matchedAgent identifies an agent a selector resolved to.
+type matchedAgent struct {
+	AgentID      string `json:"agent_id"`
+	ConnectionID string `json:"connection_id"`
+	Hostname     string `json:"hostname"`
+}
+
+// parseSelector builds a selector from a label list such as "env=prod,role=db"
+// and an optional hostname glob such as "web-*".
+func parseSelector(labels, hostname string) (*agentSelector, error) {
+	parsed, err := utils.ParseLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 && hostname == "" {
+		return nil, errors.New("selector must specify labels or a hostname")
+	}
+	if _, err := path.Match(hostname, ""); err != nil {
+		return nil, errors.New("invalid hostname pattern")
+	}
+	return &agentSelector{labels: parsed, hostname: hostname}, nil
+}
+
+func (sel *agentSelector) matches(metadata agentMetadata) bool {
+	for key, value := range sel.labels {
+		if actual, ok := metadata.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	if sel.hostname != "" {
+		if ok, _ := path.Match(sel.hostname, metadata.Hostname); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveSelector returns the connected agents matching sel.
+func (s *SysWatchServer) resolveSelector(sel *agentSelector) []matchedAgent {
+	matched := []matchedAgent{}
+	for _, agent := range s.getAgents() {
+		if agent.Connected && sel.matches(agent.agentMetadata) {
+			matched = append(matched, matchedAgent{
+				AgentID:      agent.AgentID,
+				ConnectionID: agent.ConnectionID,
+				Hostname:     agent.Hostname,
+			})
+		}
+	}
+	return matched
+}
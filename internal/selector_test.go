@@ -0,0 +1,95 @@
+package syswatch
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		name       string
+		labels     string
+		hostname   string
+		wantLabels map[string]string
+		valid      bool
+	}{
+		{"labels", "env=prod, role=db", "", map[string]string{"env": "prod", "role": "db"}, true},
+		{"hostname", "", "web-*", map[string]string{}, true},
+		{"both", "env=prod", "web-[0-9]*", map[string]string{"env": "prod"}, true},
+		{"empty value", "env=", "", map[string]string{"env": ""}, true},
+		{"nothing", "", "", nil, false},
+		{"only commas", ",,", "", nil, false},
+		{"missing value", "env", "", nil, false},
+		{"missing key", "=prod", "", nil, false},
+		{"malformed glob", "", "web-[", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := parseSelector(tt.labels, tt.hostname)
+			if (err == nil) != tt.valid {
+				t.Fatalf("parseSelector() = %v, want valid %v", err, tt.valid)
+			}
+			if tt.valid && (!reflect.DeepEqual(sel.labels, tt.wantLabels) || sel.hostname != tt.hostname) {
+				t.Errorf("parseSelector() = %v %q, want %v %q", sel.labels, sel.hostname, tt.wantLabels, tt.hostname)
+			}
+		})
+	}
+}
+
+func TestResolveSelector(t *testing.T) {
+	s := newTestServer(t, ServerConfig{})
+	agents := []struct {
+		agentID   string
+		hostname  string
+		labels    map[string]string
+		connected bool
+	}{
+		{"a1", "web-01", map[string]string{"env": "prod", "role": "web"}, true},
+		{"a2", "web-02", map[string]string{"env": "staging", "role": "web"}, true},
+		{"a3", "db-01", map[string]string{"env": "prod", "role": "db"}, true},
+		{"a4", "web-03", map[string]string{"env": "prod", "role": "web"}, false},
+		{"a5", "web-04", nil, true},
+	}
+	for _, a := range agents {
+		record := &agentRecord{agentID: a.agentID, metadata: agentMetadata{Hostname: a.hostname, Labels: a.labels}}
+		if a.connected {
+			record.connectionID = "conn-" + a.agentID
+		}
+		s.agents.Store(a.agentID, record)
+	}
+
+	tests := []struct {
+		name     string
+		labels   string
+		hostname string
+		want     []string
+	}{
+		{"one label", "env=prod", "", []string{"a1", "a3"}},
+		{"all labels must match", "env=prod,role=web", "", []string{"a1"}},
+		{"label value must match", "env=dev", "", nil},
+		{"hostname glob", "", "web-*", []string{"a1", "a2", "a5"}},
+		{"hostname character class", "", "web-0[2-4]", []string{"a2", "a5"}},
+		{"hostname and label", "role=web", "*-01", []string{"a1"}},
+		{"exact hostname", "", "db-01", []string{"a3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := parseSelector(tt.labels, tt.hostname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, agent := range s.resolveSelector(sel) {
+				if agent.ConnectionID != "conn-"+agent.AgentID {
+					t.Errorf("agent %s resolved to connection %s", agent.AgentID, agent.ConnectionID)
+				}
+				got = append(got, agent.AgentID)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
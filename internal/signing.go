@@ -0,0 +1,46 @@
+package syswatch
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SignRequest signs the command in body, a /send request body, for the agent
+// target until expires. It returns body with the command ID, target, expiry
+// and signature added, and every other field passed through unchanged.
+//
+// The signature covers the command as the server will send it to the agent,
+// so the request is resolved the same way /send resolves it.
+func SignRequest(body []byte, key ed25519.PrivateKey, target string, expires time.Time) ([]byte, error) {
+	var request map[string]interface{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	var spec commandSpec
+	if err := json.Unmarshal(body, &spec); err != nil {
+		return nil, err
+	}
+	if spec.Template != "" {
+		return nil, errSignedTemplate
+	}
+
+	spec.CommandID = uuid.New().String()
+	spec.Target = target
+	spec.ExpiresAt = expires.Unix()
+	if err := spec.resolve(); err != nil {
+		return nil, err
+	}
+	spec.Signature = spec.proto(spec.CommandID, "").SignedFields().Sign(key)
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+
+	request["command_id"] = spec.CommandID
+	request["target"] = spec.Target
+	request["expires_at"] = spec.ExpiresAt
+	request["signature"] = spec.Signature
+	return json.Marshal(request)
+}
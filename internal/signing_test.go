@@ -0,0 +1,135 @@
+package syswatch
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	pb "github.com/clwg/syswatch/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// agentReceives decodes body as /send does and returns the command as the
+// agent receives it.
+func agentReceives(t *testing.T, body []byte) (*pb.Command, error) {
+	t.Helper()
+	var spec commandSpec
+	if err := json.Unmarshal(body, &spec); err != nil {
+		t.Fatalf("decoding signed request: %v", err)
+	}
+	if err := (&SysWatchServer{}).resolveCommand(&spec); err != nil {
+		return nil, err
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+
+	wire, err := proto.Marshal(spec.proto(spec.CommandID, "alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var command pb.Command
+	if err := proto.Unmarshal(wire, &command); err != nil {
+		t.Fatal(err)
+	}
+	return &command, nil
+}
+
+func TestSignRequestVerifiesOnAgent(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := []string{
+		`{"message":"uptime","wait":true}`,
+		`{"message":"ps aux | grep 'ssh d'","shell":true}`,
+		`{"program":"grep","arguments":["-c","Failed password","/var/log/auth.log"]}`,
+		`{"program":"uptime","arguments":[]}`,
+		`{"message":"./backup.sh","timeout":120,"cwd":"/opt/backup","env":{"B":"2","A":"1"},"user":"backup"}`,
+		`{"message":"uptime","env":{},"max_output_bytes":4096}`,
+		`{"message":"make","limits":{"cpu_seconds":60,"memory_bytes":1048576,"nice":10,"io_class":"best-effort","io_priority":7}}`,
+	}
+	for _, body := range bodies {
+		t.Run(body, func(t *testing.T) {
+			signed, err := SignRequest([]byte(body), private, "agent", time.Now().Add(time.Minute))
+			if err != nil {
+				t.Fatalf("SignRequest() = %v", err)
+			}
+			command, err := agentReceives(t, signed)
+			if err != nil {
+				t.Fatalf("server rejected the signed request: %v", err)
+			}
+			if err := command.SignedFields().Verify(public, command.GetSignature()); err != nil {
+				t.Errorf("agent failed to verify the signed request: %v", err)
+			}
+
+			var fields map[string]interface{}
+			if err := json.Unmarshal(signed, &fields); err != nil {
+				t.Fatal(err)
+			}
+			var original map[string]interface{}
+			json.Unmarshal([]byte(body), &original)
+			for key := range original {
+				if _, ok := fields[key]; !ok {
+					t.Errorf("field %s was not passed through", key)
+				}
+			}
+		})
+	}
+}
+
+func TestSignRequestTampered(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"message":"uptime","env":{"A":"1"},"timeout":10}`
+	signed, err := SignRequest([]byte(body), private, "agent", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := map[string]interface{}{
+		"message":    "uptime; id",
+		"shell":      true,
+		"env":        map[string]string{"A": "1", "LD_PRELOAD": "/tmp/x.so"},
+		"timeout":    3600,
+		"user":       "root",
+		"cwd":        "/tmp",
+		"target":     "other",
+		"expires_at": time.Now().Add(time.Hour).Unix(),
+		"limits":     map[string]int{"nice": 1},
+	}
+	for field, value := range changes {
+		t.Run(field, func(t *testing.T) {
+			var request map[string]interface{}
+			if err := json.Unmarshal(signed, &request); err != nil {
+				t.Fatal(err)
+			}
+			request[field] = value
+			tampered, _ := json.Marshal(request)
+
+			command, err := agentReceives(t, tampered)
+			if err != nil {
+				return // Refused by the server already
+			}
+			if err := command.SignedFields().Verify(public, command.GetSignature()); err == nil {
+				t.Errorf("agent verified a request with %s changed", field)
+			}
+		})
+	}
+}
+
+func TestSignRequestRefusesTemplates(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"template":"disk_usage","args":{"path":"/var/log"}}`
+	if _, err := SignRequest([]byte(body), private, "agent", time.Now().Add(time.Minute)); err == nil {
+		t.Error("SignRequest() of a template succeeded, want an error")
+	}
+}
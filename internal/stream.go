@@ -0,0 +1,116 @@
+package syswatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	pb "github.com/clwg/syswatch/proto"
+)
+
+// outputBacklog is how many output chunks of a streaming command are held
+// for a slow HTTP client before further chunks are dropped.
+const outputBacklog = 256
+
+// commandOutput is a chunk of output from a streaming command.
+type commandOutput struct {
+	Seq    uint64 `json:"seq"`
+	Stream string `json:"stream"`
+	Data   string `json:"data"`
+}
+
+// forwardOutput hands a chunk of streaming output to whoever is relaying it,
+// or collects the output of a non-streaming command until its result
+// arrives, discarding any past the command's max_output_bytes. Streamed chunks are dropped rather than stalling the stream when
+// the relay falls behind or has gone away; the gap shows in the sequence
+// numbers.
+func (s *SysWatchServer) forwardOutput(connID string, output *pb.CommandOutput) {
+	pending, ok := s.lookupCommand(connID, output.GetCommandId())
+	if !ok {
+		return
+	}
+	if !pending.stream {
+		buf := &pending.stdout
+		if output.GetStream() == "stderr" {
+			buf = &pending.stderr
+		}
+		data := output.GetData()
+		if room := pending.maxOutput - int64(buf.Len()); int64(len(data)) > room {
+			data = data[:max(room, 0)]
+			pending.outputTruncated = true
+		}
+		buf.Write(data)
+		return
+	}
+
+	s.logger.Log(connID + " | output | " + output.GetCommandId() + " | " + output.GetStream() + " | " + strings.TrimRight(string(output.GetData()), "\n"))
+	select {
+	case pending.output <- &commandOutput{Seq: output.GetSeq(), Stream: output.GetStream(), Data: string(output.GetData())}:
+	default:
+		if !pending.droppingOutput {
+			log.Printf("Dropping output of command %s from chunk %d, the client is not keeping up", output.GetCommandId(), output.GetSeq())
+			pending.droppingOutput = true
+		}
+	}
+}
+
+// streamCommand relays a streaming command to the HTTP client as server-sent
+// events: a command event with the command ID, an output event per chunk and
+// finally a result or timeout event.
+func (s *SysWatchServer) streamCommand(w http.ResponseWriter, r *http.Request, pending *pendingCommand, commandID string, timeout time.Duration) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	writeEvent(w, "command", map[string]string{"command_id": commandID})
+	flusher.Flush()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case output := <-pending.output:
+			writeEvent(w, "output", output)
+		case result := <-pending.result:
+			// The agent sends every chunk before the result, so whatever is
+			// still queued belongs before it.
+			for drained := false; !drained; {
+				select {
+				case output := <-pending.output:
+					writeEvent(w, "output", output)
+				default:
+					drained = true
+				}
+			}
+			writeEvent(w, "result", result)
+			flusher.Flush()
+			return
+		case <-timer.C:
+			writeEvent(w, "timeout", map[string]string{"command_id": commandID, "message": "Timed out waiting for command reply"})
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes v as a JSON server-sent event.
+func writeEvent(w io.Writer, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", event, err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
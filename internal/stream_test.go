@@ -0,0 +1,63 @@
+package syswatch
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	logwriter "github.com/clwg/go-rotating-logger"
+	pb "github.com/clwg/syswatch/proto"
+)
+
+func newTestServer(t *testing.T, config ServerConfig) *SysWatchServer {
+	t.Helper()
+	logger, err := logwriter.NewLogger(logwriter.LoggerConfig{
+		FilenamePrefix: "syswatch",
+		LogDir:         t.TempDir(),
+		MaxLines:       1000,
+		RotationTime:   time.Hour,
+		LogFormat:      logwriter.FormatText,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Logger = logger
+	return InitializeSysWatchServer(config)
+}
+
+func TestForwardOutputCapsCollectedOutput(t *testing.T) {
+	s := newTestServer(t, ServerConfig{})
+	pending := &pendingCommand{connID: "conn", result: make(chan *commandResult, 1), maxOutput: 10}
+	s.pending.Store("cmd", pending)
+
+	for seq := uint64(0); seq < 5; seq++ {
+		s.forwardOutput("conn", &pb.CommandOutput{CommandId: "cmd", Seq: seq, Stream: "stdout", Data: []byte("abcd")})
+	}
+	s.forwardOutput("conn", &pb.CommandOutput{CommandId: "cmd", Seq: 5, Stream: "stderr", Data: []byte("err")})
+	s.completeCommand("conn", &pb.CommandResult{CommandId: "cmd", OutputChunked: true})
+
+	result := <-pending.result
+	if result.Stdout != "abcdabcdab" || result.Stderr != "err" {
+		t.Errorf("stdout %q, stderr %q, want the first 10 bytes of stdout and all of stderr", result.Stdout, result.Stderr)
+	}
+	if !result.Truncated {
+		t.Error("result is not truncated")
+	}
+	if pending.stdout.Len() > 10 || !bytes.Equal(pending.stdout.Bytes(), []byte(result.Stdout)) {
+		t.Errorf("collected %d bytes of stdout, want at most 10", pending.stdout.Len())
+	}
+}
+
+func TestForwardOutputWithinCap(t *testing.T) {
+	s := newTestServer(t, ServerConfig{})
+	pending := &pendingCommand{connID: "conn", result: make(chan *commandResult, 1), maxOutput: 8}
+	s.pending.Store("cmd", pending)
+
+	s.forwardOutput("conn", &pb.CommandOutput{CommandId: "cmd", Stream: "stdout", Data: []byte("abcd")})
+	s.forwardOutput("conn", &pb.CommandOutput{CommandId: "cmd", Seq: 1, Stream: "stdout", Data: []byte("efgh")})
+	s.completeCommand("conn", &pb.CommandResult{CommandId: "cmd", OutputChunked: true})
+
+	if result := <-pending.result; result.Stdout != "abcdefgh" || result.Truncated {
+		t.Errorf("stdout %q, truncated %v, want all 8 bytes untruncated", result.Stdout, result.Truncated)
+	}
+}
@@ -0,0 +1,82 @@
+package syswatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/clwg/syswatch/utils"
+)
+
+// CommandTemplates is the catalog of named commands operators can dispatch
+// with arguments instead of typing out a command.
+type CommandTemplates map[string]*utils.Template
+
+// LoadCommandTemplates reads a JSON object of templates keyed by name, such as
+// {"disk_usage": {"command": "du -sh {path}", "params": {"path": {"type": "path"}}}}.
+func LoadCommandTemplates(filename string) (CommandTemplates, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var templates CommandTemplates
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for name, template := range templates {
+		if template == nil {
+			return nil, fmt.Errorf("%s: template %s is empty", filename, name)
+		}
+		if err := template.Compile(); err != nil {
+			return nil, fmt.Errorf("%s: template %s: %v", filename, name, err)
+		}
+	}
+	return templates, nil
+}
+
+// renderTemplate replaces the template reference in spec with the command it
+// renders to, after validating the arguments against the template.
+func (s *SysWatchServer) renderTemplate(spec *commandSpec) error {
+	template, ok := s.templates[spec.Template]
+	if !ok {
+		return fmt.Errorf("unknown template %s", spec.Template)
+	}
+	command, argv, err := template.Render(spec.Args)
+	if err != nil {
+		return fmt.Errorf("template %s: %w", spec.Template, err)
+	}
+
+	if template.User != "" {
+		if spec.User != "" && spec.User != template.User {
+			return fmt.Errorf("template %s runs as %s", spec.Template, template.User)
+		}
+		spec.User = template.User
+	}
+
+	spec.Message = command
+	spec.Shell = argv == nil
+	if argv != nil {
+		spec.Program, spec.Arguments = argv[0], argv[1:]
+	}
+	return nil
+}
+
+// listTemplates reports the command catalog and each template's parameters.
+func (s *SysWatchServer) listTemplates(w http.ResponseWriter, r *http.Request) {
+	type namedTemplate struct {
+		Name string `json:"name"`
+		*utils.Template
+	}
+
+	templates := []namedTemplate{}
+	for name, template := range s.templates {
+		templates = append(templates, namedTemplate{Name: name, Template: template})
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
@@ -0,0 +1,45 @@
+package syswatch
+
+import (
+	"math"
+	"time"
+
+	"github.com/clwg/syswatch/utils"
+)
+
+// SignedFields returns the parts of c an operator signs. Both the signer and
+// the agent derive them from the command as the server sends it, so that they
+// sign and verify the same payload.
+func (c *Command) SignedFields() utils.SignedCommand {
+	return utils.SignedCommand{
+		CommandID: c.GetCommandId(),
+		Target:    c.GetTarget(),
+		ExpiresAt: c.GetExpiresAt(),
+
+		Command:        c.GetCommand(),
+		Shell:          c.GetShell(),
+		Program:        c.GetProgram(),
+		Arguments:      c.GetArguments(),
+		Template:       c.GetTemplate(),
+		Args:           c.GetArgs(),
+		Cwd:            c.GetCwd(),
+		Env:            c.GetEnv(),
+		User:           c.GetUser(),
+		Timeout:        c.GetTimeout().AsDuration(),
+		MaxOutputBytes: c.GetMaxOutputBytes(),
+		Limits:         c.GetLimits().ResourceLimits(),
+	}
+}
+
+// ResourceLimits converts the limits requested for a command, which impose no
+// limits when l is nil.
+func (l *ResourceLimits) ResourceLimits() utils.ResourceLimits {
+	return utils.ResourceLimits{
+		CPUTime:      time.Duration(min(l.GetCpuSeconds(), math.MaxInt64/uint64(time.Second))) * time.Second,
+		MemoryBytes:  int64(min(l.GetMemoryBytes(), math.MaxInt64)),
+		MaxProcesses: int(min(l.GetMaxProcesses(), math.MaxInt32)),
+		Nice:         int(l.GetNice()),
+		IOClass:      l.GetIoClass(),
+		IOPriority:   int(l.GetIoPriority()),
+	}
+}
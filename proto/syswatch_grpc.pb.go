@@ -22,8 +22,10 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type SysWatchClient interface {
+	// The first message the server sends on a stream is a Handshake carrying the
+	// connection ID assigned to it. Every message the client sends afterwards
+	// must carry that connection ID.
 	BidirectionalStreamPayload(ctx context.Context, opts ...grpc.CallOption) (SysWatch_BidirectionalStreamPayloadClient, error)
-	GenerateUUID(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UUIDResponse, error)
 }
 
 type sysWatchClient struct {
@@ -65,21 +67,14 @@ func (x *sysWatchBidirectionalStreamPayloadClient) Recv() (*ResponseMessage, err
 	return m, nil
 }
 
-func (c *sysWatchClient) GenerateUUID(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UUIDResponse, error) {
-	out := new(UUIDResponse)
-	err := c.cc.Invoke(ctx, "/syswatch.SysWatch/GenerateUUID", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
 // SysWatchServer is the server API for SysWatch service.
 // All implementations must embed UnimplementedSysWatchServer
 // for forward compatibility
 type SysWatchServer interface {
+	// The first message the server sends on a stream is a Handshake carrying the
+	// connection ID assigned to it. Every message the client sends afterwards
+	// must carry that connection ID.
 	BidirectionalStreamPayload(SysWatch_BidirectionalStreamPayloadServer) error
-	GenerateUUID(context.Context, *Empty) (*UUIDResponse, error)
 	mustEmbedUnimplementedSysWatchServer()
 }
 
@@ -90,9 +85,6 @@ type UnimplementedSysWatchServer struct {
 func (UnimplementedSysWatchServer) BidirectionalStreamPayload(SysWatch_BidirectionalStreamPayloadServer) error {
 	return status.Errorf(codes.Unimplemented, "method BidirectionalStreamPayload not implemented")
 }
-func (UnimplementedSysWatchServer) GenerateUUID(context.Context, *Empty) (*UUIDResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GenerateUUID not implemented")
-}
 func (UnimplementedSysWatchServer) mustEmbedUnimplementedSysWatchServer() {}
 
 // UnsafeSysWatchServer may be embedded to opt out of forward compatibility for this service.
@@ -132,36 +124,13 @@ func (x *sysWatchBidirectionalStreamPayloadServer) Recv() (*RequestMessage, erro
 	return m, nil
 }
 
-func _SysWatch_GenerateUUID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Empty)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(SysWatchServer).GenerateUUID(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/syswatch.SysWatch/GenerateUUID",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(SysWatchServer).GenerateUUID(ctx, req.(*Empty))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
 // SysWatch_ServiceDesc is the grpc.ServiceDesc for SysWatch service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var SysWatch_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "syswatch.SysWatch",
 	HandlerType: (*SysWatchServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "GenerateUUID",
-			Handler:    _SysWatch_GenerateUUID_Handler,
-		},
-	},
+	Methods:     []grpc.MethodDesc{},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "BidirectionalStreamPayload",
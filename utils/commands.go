@@ -1,77 +1,217 @@
 package utils
 
 import (
-	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
+	"sort"
+	"syscall"
 	"time"
 )
 
-func ExecuteCommand(cmdStr string, timeoutSecs ...int) (string, error) {
-	var cmd *exec.Cmd
-	timeout := 10 // default timeout in seconds stops certain commands from running indefinitely (i.e. ping)
+// CommandResult describes how a command executed by ExecuteCommand ended.
+type CommandResult struct {
+	Stdout    string
+	Stderr    string
+	ExitCode  int    // -1 when the process was terminated by a signal
+	Signal    string // Signal that terminated the process, if any
+	StartTime time.Time
+	EndTime   time.Time
+	Duration  time.Duration
+	TimedOut  bool
+	Cancelled bool // The caller's context was cancelled while the command ran
 
-	if len(timeoutSecs) > 0 { // simple check to see if a timeout was provided
-		timeout = timeoutSecs[0]
-	}
+	StdoutBytes int64 // Bytes the command wrote to stdout, including any not kept
+	StderrBytes int64
+	Truncated   bool // Stdout or Stderr was cut off at CommandOptions.MaxOutputBytes
+}
 
-	// Create a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer cancel()
+// DefaultCommandTimeout stops certain commands from running indefinitely (i.e. ping)
+// when no timeout is given.
+const DefaultCommandTimeout = 10 * time.Second
+
+// DefaultKillGrace is how long a command has to exit after SIGTERM before it
+// is killed, when no grace period is given.
+const DefaultKillGrace = 5 * time.Second
+
+// DefaultMaxOutputBytes is how much of each of stdout and stderr is kept when
+// no limit is given.
+const DefaultMaxOutputBytes = 1 << 20
+
+// CommandOptions controls how ExecuteCommand runs a command.
+type CommandOptions struct {
+	Timeout time.Duration     // DefaultCommandTimeout when zero
+	Dir     string            // Working directory, the current one when empty
+	Env     map[string]string // Added to the current environment
+	User    string            // Run as this user, a name or uid, rather than the current one
+	Group   string            // Primary group, a name or gid, of User, its own when empty
+
+	// KillGrace is how long the command has to exit after being asked to
+	// terminate on timeout or cancellation, DefaultKillGrace when zero.
+	KillGrace time.Duration
+
+	// MaxOutputBytes is how much of each of stdout and stderr is kept in the
+	// result or passed to Output, DefaultMaxOutputBytes when zero. Output past
+	// it is discarded.
+	MaxOutputBytes int64
+
+	// Limits bounds the resources the command may use. Memory and process
+	// limits are enforced by a cgroup created for the command under
+	// CgroupParent when one is given, which must be a cgroup v2 directory
+	// with the memory and pids controllers enabled for its children; process
+	// limits require one. The other limits are set before the command is
+	// executed, which requires the program to call RunLimitsHelper.
+	Limits       ResourceLimits
+	CgroupParent string
+
+	// Output, when set, receives output as the command produces it instead of
+	// it being collected in the result. It may be called concurrently for
+	// stdout and stderr.
+	Output func(stream string, data []byte)
+}
 
+// ShellCommand returns the argv that runs cmdStr through the platform shell.
+func ShellCommand(cmdStr string) ([]string, error) {
 	switch runtime.GOOS {
 	case "linux", "darwin":
-		cmd = exec.CommandContext(ctx, "/bin/bash", "-c", cmdStr)
+		return []string{"/bin/bash", "-c", cmdStr}, nil
 	case "windows":
-		cmd = exec.CommandContext(ctx, "cmd.exe", "/C", cmdStr)
+		return []string{"cmd.exe", "/C", cmdStr}, nil
 	default:
-		return "", fmt.Errorf("unsupported platform")
+		return nil, fmt.Errorf("unsupported platform")
+	}
+}
+
+// ExecuteCommand executes argv directly, without a shell; use ShellCommand to
+// run a command line through the shell. On timeout, or when ctx is
+// cancelled, the command and any processes it started are sent SIGTERM and,
+// after KillGrace, SIGKILL. A command that runs but fails, or is killed, is
+// reported through the result along with the signal that ended it; an error
+// is only returned when the command could not be started.
+func ExecuteCommand(ctx context.Context, argv []string, opts CommandOptions) (*CommandResult, error) {
+	if len(argv) == 0 {
+		return nil, errors.New("empty command")
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := opts.Limits.Validate(); err != nil {
+		return nil, err
+	}
+	argv, err := limitedArgv(argv, opts.Limits, opts.CgroupParent != "")
+	if err != nil {
+		return nil, err
 	}
 
-	// Get a pipe to read from standard output
-	stdoutPipe, err := cmd.StdoutPipe()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	grace := opts.KillGrace
+	if grace <= 0 {
+		grace = DefaultKillGrace
+	}
+	stopKilling := killProcessGroup(cmd, grace)
+	defer stopKilling()
+	cmd.Dir = opts.Dir
+	if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), formatEnv(opts.Env)...)
+	}
+	if opts.Group != "" && opts.User == "" {
+		return nil, errors.New("a group requires a user")
+	}
+	if opts.User != "" {
+		if err := runAsUser(cmd, opts.User, opts.Group); err != nil {
+			return nil, err
+		}
+	}
+
+	maxOutput := opts.MaxOutputBytes
+	if maxOutput <= 0 {
+		maxOutput = DefaultMaxOutputBytes
+	}
+	out := &outputWriter{stream: "stdout", output: opts.Output, limit: maxOutput}
+	errOut := &outputWriter{stream: "stderr", output: opts.Output, limit: maxOutput}
+	cmd.Stdout = out
+	cmd.Stderr = errOut
+
+	limiter, err := newResourceLimiter(cmd, opts.Limits, opts.CgroupParent)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer limiter.release()
 
-	// Start the command
+	result := &CommandResult{StartTime: time.Now()}
 	if err := cmd.Start(); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	var out bytes.Buffer
-	done := make(chan error, 1)
+	waitErr := cmd.Wait()
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+	result.Stdout = out.buf.String()
+	result.Stderr = errOut.buf.String()
+	result.StdoutBytes = out.total
+	result.StderrBytes = errOut.total
+	result.Truncated = out.total > out.kept || errOut.total > errOut.kept
+	result.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	result.Cancelled = errors.Is(ctx.Err(), context.Canceled)
 
-	go func() {
-		scanner := bufio.NewScanner(stdoutPipe)
-		for scanner.Scan() {
-			line := scanner.Text()
-			out.WriteString(line + "\n")
-			//fmt.Println(line) // Optionally, print the line to stdout or handle it otherwise
-		}
-		done <- scanner.Err()
-	}()
-
-	select {
-	case <-ctx.Done():
-		// Timeout reached, kill the process and return what we have so far
-		if killErr := cmd.Process.Kill(); killErr != nil {
-			return fmt.Sprintf("%s\nCommand timed out after %d seconds", out.String(), timeout), nil
-		}
-		<-done // Allow goroutine to exit
-		return fmt.Sprintf("%s\nCommand timed out after %d seconds", out.String(), timeout), nil
-	case err := <-done:
-		// Command finished
-		if err != nil {
-			return out.String(), err
-		}
-		if err := cmd.Wait(); err != nil {
-			return out.String(), err
-		}
+	if cmd.ProcessState == nil {
+		return nil, waitErr
 	}
+	result.ExitCode = cmd.ProcessState.ExitCode()
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		result.Signal = status.Signal().String()
+	}
+
+	return result, nil
+}
+
+// outputWriter collects one of a command's output streams, or hands each
+// write to a CommandOptions.Output callback, keeping or passing on up to limit
+// bytes either way.
+type outputWriter struct {
+	stream string
+	output func(stream string, data []byte)
+	limit  int64
+	buf    bytes.Buffer
+	total  int64
+	kept   int64 // Bytes collected or passed to output
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.total += int64(len(p))
 
-	return out.String(), nil
+	// Output past the limit is discarded rather than failing the write, which
+	// would leave the command to die of a broken pipe.
+	room := w.limit - w.kept
+	if room <= 0 {
+		return len(p), nil
+	}
+	kept := p[:min(int64(len(p)), room)]
+	w.kept += int64(len(kept))
+	if w.output != nil {
+		w.output(w.stream, append([]byte(nil), kept...))
+	} else {
+		w.buf.Write(kept)
+	}
+	return len(p), nil
+}
+
+// formatEnv renders env as sorted KEY=VALUE pairs.
+func formatEnv(env map[string]string) []string {
+	pairs := make([]string, 0, len(env))
+	for key, value := range env {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return pairs
 }
@@ -0,0 +1,285 @@
+//go:build !windows
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecuteCommandResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		argv       []string
+		opts       CommandOptions
+		wantStdout string
+		wantStderr string
+		wantExit   int
+	}{
+		{"success", []string{"sh", "-c", "echo out"}, CommandOptions{}, "out\n", "", 0},
+		{"stderr and exit code", []string{"sh", "-c", "echo err >&2; exit 3"}, CommandOptions{}, "", "err\n", 3},
+		{"working directory", []string{"sh", "-c", "pwd"}, CommandOptions{Dir: "/"}, "/\n", "", 0},
+		{"environment", []string{"sh", "-c", `echo "$SYSWATCH_TEST"`}, CommandOptions{Env: map[string]string{"SYSWATCH_TEST": "set"}}, "set\n", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteCommand(context.Background(), tt.argv, tt.opts)
+			if err != nil {
+				t.Fatalf("ExecuteCommand() = %v", err)
+			}
+			if result.Stdout != tt.wantStdout || result.Stderr != tt.wantStderr || result.ExitCode != tt.wantExit {
+				t.Errorf("stdout %q, stderr %q, exit code %d; want %q, %q, %d", result.Stdout, result.Stderr, result.ExitCode, tt.wantStdout, tt.wantStderr, tt.wantExit)
+			}
+			if result.Signal != "" || result.TimedOut || result.Cancelled || result.Truncated {
+				t.Errorf("result = %+v, want a command that ran to completion", result)
+			}
+			if result.StdoutBytes != int64(len(tt.wantStdout)) || result.StderrBytes != int64(len(tt.wantStderr)) {
+				t.Errorf("%d stdout and %d stderr bytes, want %d and %d", result.StdoutBytes, result.StderrBytes, len(tt.wantStdout), len(tt.wantStderr))
+			}
+		})
+	}
+}
+
+func TestExecuteCommandTiming(t *testing.T) {
+	result, err := ExecuteCommand(context.Background(), []string{"sh", "-c", "sleep 0.2"}, CommandOptions{})
+	if err != nil {
+		t.Fatalf("ExecuteCommand() = %v", err)
+	}
+	if result.Duration < 200*time.Millisecond || result.Duration != result.EndTime.Sub(result.StartTime) {
+		t.Errorf("started %v, ended %v, took %v; want at least 200ms between them", result.StartTime, result.EndTime, result.Duration)
+	}
+}
+
+func TestExecuteCommandEmpty(t *testing.T) {
+	if _, err := ExecuteCommand(context.Background(), nil, CommandOptions{}); err == nil {
+		t.Error("ExecuteCommand() of an empty argv succeeded")
+	}
+	if _, err := ExecuteCommand(context.Background(), []string{"sh"}, CommandOptions{Group: "nogroup"}); err == nil {
+		t.Error("ExecuteCommand() with a group but no user succeeded")
+	}
+}
+
+func TestFormatEnv(t *testing.T) {
+	got := strings.Join(formatEnv(map[string]string{"B": "2", "A": "1=1"}), " ")
+	if got != "A=1=1 B=2" {
+		t.Errorf("formatEnv() = %q, want sorted KEY=VALUE pairs", got)
+	}
+}
+
+func TestExecuteCommandArgvAndShell(t *testing.T) {
+	const line = "echo a; echo $HOME | tr a-z A-Z"
+	shell, err := ShellCommand(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		argv []string
+		want string
+	}{
+		// Arguments reach the program as they are, metacharacters and all.
+		{"argv", []string{"echo", line}, line + "\n"},
+		{"argv with quotes", []string{"printf", "%s|", "a b", `"c"`, "$(id)"}, `a b|"c"|$(id)|`},
+		{"shell", shell, "a\n" + strings.ToUpper(os.Getenv("HOME")) + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteCommand(context.Background(), tt.argv, CommandOptions{})
+			if err != nil {
+				t.Fatalf("ExecuteCommand() = %v", err)
+			}
+			if result.Stdout != tt.want {
+				t.Errorf("stdout = %q, want %q", result.Stdout, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteCommandMissingBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		argv []string
+	}{
+		{"not on the path", []string{"syswatch-no-such-command"}},
+		{"absolute path", []string{"/nonexistent/syswatch"}},
+		{"directory", []string{"/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteCommand(context.Background(), tt.argv, CommandOptions{})
+			if err == nil {
+				t.Errorf("ExecuteCommand() = %+v, want an error", result)
+			}
+		})
+	}
+}
+
+func TestExecuteCommandTruncation(t *testing.T) {
+	// 100 bytes of stdout in 10 writes and 5 of stderr.
+	argv := []string{"sh", "-c", "for i in 0 1 2 3 4 5 6 7 8 9; do printf 012345678$i; done; printf abcde >&2"}
+	var all strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&all, "012345678%d", i)
+	}
+	tests := []struct {
+		name          string
+		limit         int64
+		stream        bool
+		wantStdout    string
+		wantStderr    string
+		wantTruncated bool
+	}{
+		{"under the limit", 100, false, all.String(), "abcde", false},
+		{"stdout over the limit", 25, false, "0123456780012345678101234", "abcde", true},
+		{"both over the limit", 4, false, "0123", "abcd", true},
+		{"streamed under the limit", 100, true, all.String(), "abcde", false},
+		{"streamed over the limit", 25, true, "0123456780012345678101234", "abcde", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := CommandOptions{MaxOutputBytes: tt.limit}
+			var mu sync.Mutex
+			streamed := map[string]*strings.Builder{"stdout": {}, "stderr": {}}
+			if tt.stream {
+				opts.Output = func(stream string, data []byte) {
+					mu.Lock()
+					defer mu.Unlock()
+					streamed[stream].Write(data)
+				}
+			}
+
+			result, err := ExecuteCommand(context.Background(), argv, opts)
+			if err != nil {
+				t.Fatalf("ExecuteCommand() = %v", err)
+			}
+			stdout, stderr := result.Stdout, result.Stderr
+			if tt.stream {
+				if stdout != "" || stderr != "" {
+					t.Errorf("streamed output was also collected: %q, %q", stdout, stderr)
+				}
+				stdout, stderr = streamed["stdout"].String(), streamed["stderr"].String()
+			}
+			if stdout != tt.wantStdout || stderr != tt.wantStderr {
+				t.Errorf("kept %q and %q, want %q and %q", stdout, stderr, tt.wantStdout, tt.wantStderr)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+			if result.StdoutBytes != 100 || result.StderrBytes != 5 {
+				t.Errorf("%d stdout and %d stderr bytes, want the full 100 and 5", result.StdoutBytes, result.StderrBytes)
+			}
+		})
+	}
+}
+
+// processGone reports whether pid has exited, counting a zombie that nothing
+// has reaped yet as gone.
+func processGone(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return true
+	}
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) > 0 && fields[0] == "Z"
+}
+
+// waitGone waits briefly for pid to exit.
+func waitGone(pid int) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if processGone(pid) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExecuteCommandTermination(t *testing.T) {
+	tests := []struct {
+		name          string
+		script        string
+		timeout       time.Duration
+		cancelAfter   time.Duration
+		grace         time.Duration
+		wantSignal    string
+		wantTimedOut  bool
+		wantCancelled bool
+		minDuration   time.Duration
+		maxDuration   time.Duration
+	}{
+		{
+			name:    "timeout",
+			script:  "sleep 30 & echo $!; wait",
+			timeout: 100 * time.Millisecond, grace: 5 * time.Second,
+			wantSignal: "terminated", wantTimedOut: true,
+			maxDuration: 2 * time.Second,
+		},
+		{
+			name:    "cancel",
+			script:  "sleep 30 & echo $!; wait",
+			timeout: time.Minute, cancelAfter: 100 * time.Millisecond, grace: 5 * time.Second,
+			wantSignal: "terminated", wantCancelled: true,
+			maxDuration: 2 * time.Second,
+		},
+		{
+			// The whole group ignores SIGTERM, so it takes SIGKILL after the grace period.
+			name:    "escalation to SIGKILL",
+			script:  `trap "" TERM; sleep 30 & echo $!; wait`,
+			timeout: 100 * time.Millisecond, grace: 500 * time.Millisecond,
+			wantSignal: "killed", wantTimedOut: true,
+			minDuration: 600 * time.Millisecond, maxDuration: 3 * time.Second,
+		},
+		{
+			// The shell exits on SIGTERM but leaves a child that ignores it,
+			// which is killed as soon as the shell has been waited for rather
+			// than after the grace period.
+			name:    "straggler killed after wait",
+			script:  `(trap "" TERM; exec sleep 30) >/dev/null 2>&1 & echo $!; wait`,
+			timeout: 100 * time.Millisecond, grace: 10 * time.Second,
+			wantSignal: "terminated", wantTimedOut: true,
+			maxDuration: 2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelAfter > 0 {
+				time.AfterFunc(tt.cancelAfter, cancel)
+			}
+
+			result, err := ExecuteCommand(ctx, []string{"sh", "-c", tt.script}, CommandOptions{Timeout: tt.timeout, KillGrace: tt.grace})
+			if err != nil {
+				t.Fatalf("ExecuteCommand() = %v", err)
+			}
+			if result.Signal != tt.wantSignal || result.ExitCode != -1 {
+				t.Errorf("signal %q, exit code %d; want %q, -1", result.Signal, result.ExitCode, tt.wantSignal)
+			}
+			if result.TimedOut != tt.wantTimedOut || result.Cancelled != tt.wantCancelled {
+				t.Errorf("timed out %v, cancelled %v; want %v, %v", result.TimedOut, result.Cancelled, tt.wantTimedOut, tt.wantCancelled)
+			}
+			if result.Duration < tt.minDuration || result.Duration > tt.maxDuration {
+				t.Errorf("took %v, want between %v and %v", result.Duration, tt.minDuration, tt.maxDuration)
+			}
+
+			pid, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
+			if err != nil {
+				t.Fatalf("stdout %q is not a process ID", result.Stdout)
+			}
+			if !waitGone(pid) {
+				syscall.Kill(pid, syscall.SIGKILL)
+				t.Errorf("process %d outlived the command", pid)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseLabels parses a comma separated list of key=value pairs such as
+// "env=prod,role=db". An empty string yields an empty map.
+func ParseLabels(labels string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, pair := range strings.Split(labels, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
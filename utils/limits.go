@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// I/O scheduling classes a command may be run in.
+const (
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+// ResourceLimits bounds what a command may consume. Zero values impose no
+// limit. Limits are only supported on Linux.
+type ResourceLimits struct {
+	CPUTime      time.Duration // RLIMIT_CPU, rounded up to whole seconds
+	MemoryBytes  int64         // memory.max of the command's cgroup, or else RLIMIT_AS
+	MaxProcesses int           // pids.max of the command's cgroup, which it requires
+	Nice         int           // Scheduling priority from 1 to 19, the lowest
+	IOClass      string        // IOClassBestEffort or IOClassIdle
+	IOPriority   int           // 0, the highest, to 7 within IOClassBestEffort
+}
+
+// IsZero reports whether l imposes no limits at all.
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// Validate reports whether every limit is within range.
+func (l ResourceLimits) Validate() error {
+	if l.CPUTime < 0 || l.MemoryBytes < 0 || l.MaxProcesses < 0 {
+		return fmt.Errorf("cpu time, memory and process limits must not be negative")
+	}
+	if l.Nice < 0 || l.Nice > 19 {
+		return fmt.Errorf("nice must be between 0 and 19")
+	}
+	switch l.IOClass {
+	case "", IOClassIdle:
+	case IOClassBestEffort:
+		if l.IOPriority < 0 || l.IOPriority > 7 {
+			return fmt.Errorf("best-effort I/O priority must be between 0 and 7")
+		}
+	default:
+		return fmt.Errorf("unknown I/O class %q", l.IOClass)
+	}
+	return nil
+}
+
+// Tighten returns l with each limit in requested applied where it is
+// stricter, so a request can lower limits but never raise them.
+func (l ResourceLimits) Tighten(requested ResourceLimits) ResourceLimits {
+	if requested.CPUTime > 0 && (l.CPUTime == 0 || requested.CPUTime < l.CPUTime) {
+		l.CPUTime = requested.CPUTime
+	}
+	if requested.MemoryBytes > 0 && (l.MemoryBytes == 0 || requested.MemoryBytes < l.MemoryBytes) {
+		l.MemoryBytes = requested.MemoryBytes
+	}
+	if requested.MaxProcesses > 0 && (l.MaxProcesses == 0 || requested.MaxProcesses < l.MaxProcesses) {
+		l.MaxProcesses = requested.MaxProcesses
+	}
+	l.Nice = max(l.Nice, requested.Nice)
+	if ioRank(requested) > ioRank(l) {
+		l.IOClass, l.IOPriority = requested.IOClass, requested.IOPriority
+	}
+	return l
+}
+
+// ioRank orders I/O scheduling settings from the most to the least favoured.
+func ioRank(l ResourceLimits) int {
+	switch l.IOClass {
+	case IOClassBestEffort:
+		return 1 + l.IOPriority
+	case IOClassIdle:
+		return 9
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,235 @@
+//go:build linux
+
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioprio_set(2) constants, which x/sys/unix does not define.
+const (
+	ioprioWhoProcess  = 1
+	ioprioClassBE     = 2
+	ioprioClassIdle   = 3
+	ioprioClassShift  = 13
+	cgroupRemoveTries = 20
+)
+
+// limitsHelperArg marks a re-execution of the current program as the helper
+// that limits itself before executing a command. See RunLimitsHelper.
+const limitsHelperArg = "-syswatch-limits-helper"
+
+var errProcessLimitsNeedCgroup = errors.New("process limits require a cgroup parent")
+
+var cgroupCounter atomic.Uint64
+
+// CheckCgroupParent reports whether dir is a cgroup v2 directory in which
+// commands can be given cgroups of their own with memory and pids limits.
+func CheckCgroupParent(dir string) error {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(dir, &fs); err != nil {
+		return err
+	}
+	if fs.Type != unix.CGROUP2_SUPER_MAGIC {
+		return fmt.Errorf("%s is not a cgroup v2 directory", dir)
+	}
+
+	controllers, err := os.ReadFile(filepath.Join(dir, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	for _, controller := range []string{"memory", "pids"} {
+		if !strings.Contains(" "+strings.TrimSpace(string(controllers))+" ", " "+controller+" ") {
+			return fmt.Errorf("the %s controller is not enabled in %s/cgroup.subtree_control", controller, dir)
+		}
+	}
+	return nil
+}
+
+// resourceLimiter runs a command in a cgroup of its own under cgroupParent,
+// which the command starts in and which enforces its memory and process
+// limits across everything it starts.
+type resourceLimiter struct {
+	cgroupDir string
+	cgroup    *os.File
+}
+
+func newResourceLimiter(cmd *exec.Cmd, limits ResourceLimits, cgroupParent string) (*resourceLimiter, error) {
+	l := &resourceLimiter{}
+	if cgroupParent == "" {
+		if limits.MaxProcesses > 0 {
+			// RLIMIT_NPROC counts every process of the user and does not
+			// apply to root, so it cannot stand in for pids.max.
+			return nil, errProcessLimitsNeedCgroup
+		}
+		return l, nil
+	}
+	if limits.MemoryBytes == 0 && limits.MaxProcesses == 0 {
+		return l, nil
+	}
+
+	name := fmt.Sprintf("syswatch-%d-%d", os.Getpid(), cgroupCounter.Add(1))
+	l.cgroupDir = filepath.Join(cgroupParent, name)
+	if err := os.Mkdir(l.cgroupDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup: %w", err)
+	}
+
+	settings := map[string]string{}
+	if limits.MemoryBytes > 0 {
+		settings["memory.max"] = strconv.FormatInt(limits.MemoryBytes, 10)
+		settings["memory.swap.max"] = "0"
+	}
+	if limits.MaxProcesses > 0 {
+		settings["pids.max"] = strconv.Itoa(limits.MaxProcesses)
+	}
+	for file, value := range settings {
+		err := os.WriteFile(filepath.Join(l.cgroupDir, file), []byte(value), 0644)
+		if err != nil && !(file == "memory.swap.max" && os.IsNotExist(err)) {
+			l.release()
+			return nil, fmt.Errorf("failed to set %s: %w", file, err)
+		}
+	}
+
+	cgroup, err := os.Open(l.cgroupDir)
+	if err != nil {
+		l.release()
+		return nil, err
+	}
+	l.cgroup = cgroup
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cgroup.Fd())
+	return l, nil
+}
+
+// release kills anything left in the command's cgroup and removes it.
+func (l *resourceLimiter) release() {
+	if l.cgroup != nil {
+		l.cgroup.Close()
+	}
+	if l.cgroupDir == "" {
+		return
+	}
+
+	os.WriteFile(filepath.Join(l.cgroupDir, "cgroup.kill"), []byte("1"), 0644)
+	for i := 0; i < cgroupRemoveTries; i++ {
+		if err := os.Remove(l.cgroupDir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// limitedArgv returns the argv that runs argv with the limits that are not
+// left to a cgroup: CPU time, nice, I/O priority and, without a cgroup,
+// memory. These are set by a re-execution of the current program, which
+// limits itself and then executes argv, so that the command never runs
+// without them.
+func limitedArgv(argv []string, limits ResourceLimits, cgroup bool) ([]string, error) {
+	if cgroup {
+		limits.MemoryBytes = 0
+	}
+	limits.MaxProcesses = 0
+	if limits.IsZero() {
+		return argv, nil
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	path := argv[0]
+	if !strings.Contains(path, "/") {
+		if path, err = exec.LookPath(path); err != nil {
+			return nil, err
+		}
+	}
+	encoded, err := json.Marshal(limits)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{self, limitsHelperArg, string(encoded), path}, argv...), nil
+}
+
+// RunLimitsHelper turns the process into the helper that starts commands
+// with resource limits when it was started as one, and otherwise returns. A
+// program that runs commands with ResourceLimits must call it first thing in
+// main, before parsing flags, and must be executable by any user commands
+// run as.
+func RunLimitsHelper() {
+	if len(os.Args) < 5 || os.Args[1] != limitsHelperArg {
+		return
+	}
+
+	// Nice and I/O priority belong to the thread that sets them, which must
+	// be the one that executes the command.
+	runtime.LockOSThread()
+
+	var limits ResourceLimits
+	err := json.Unmarshal([]byte(os.Args[2]), &limits)
+	if err == nil {
+		err = limitSelf(limits)
+	}
+	if err == nil {
+		err = syscall.Exec(os.Args[3], os.Args[4:], os.Environ())
+	}
+	fmt.Fprintf(os.Stderr, "syswatch: %s: %v\n", os.Args[4], err)
+	if errors.Is(err, syscall.ENOENT) {
+		os.Exit(127)
+	}
+	os.Exit(126)
+}
+
+// limitSelf applies limits to the calling thread and its process. The rlimits
+// are set last so the runtime is not held to them.
+func limitSelf(limits ResourceLimits) error {
+	if limits.Nice > 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, limits.Nice); err != nil {
+			return fmt.Errorf("failed to set nice: %w", err)
+		}
+	}
+
+	var ioprio int
+	switch limits.IOClass {
+	case IOClassBestEffort:
+		ioprio = ioprioClassBE<<ioprioClassShift | limits.IOPriority
+	case IOClassIdle:
+		ioprio = ioprioClassIdle << ioprioClassShift
+	}
+	if ioprio != 0 {
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(ioprio)); errno != 0 {
+			return fmt.Errorf("failed to set I/O priority: %w", errno)
+		}
+	}
+
+	if limits.CPUTime > 0 {
+		seconds := uint64((limits.CPUTime + time.Second - 1) / time.Second)
+		// SIGXCPU at the soft limit, SIGKILL a second later.
+		if err := unix.Setrlimit(unix.RLIMIT_CPU, &unix.Rlimit{Cur: seconds, Max: seconds + 1}); err != nil {
+			return fmt.Errorf("failed to limit cpu time: %w", err)
+		}
+	}
+	if limits.MemoryBytes > 0 {
+		limit := uint64(limits.MemoryBytes)
+		if err := unix.Setrlimit(unix.RLIMIT_AS, &unix.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return fmt.Errorf("failed to limit memory: %w", err)
+		}
+	}
+	return nil
+}
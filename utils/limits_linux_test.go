@@ -0,0 +1,147 @@
+//go:build linux
+
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary stand in for the agent as the limits helper.
+func TestMain(m *testing.M) {
+	RunLimitsHelper()
+	os.Exit(m.Run())
+}
+
+func TestLimitedArgv(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argv := []string{"sh", "-c", "true"}
+
+	tests := []struct {
+		name       string
+		argv       []string
+		limits     ResourceLimits
+		cgroup     bool
+		wantPath   string
+		wantLimits *ResourceLimits // Nil when argv is run as it is
+	}{
+		{"no limits", argv, ResourceLimits{}, false, "", nil},
+		{"only cgroup limits", argv, ResourceLimits{MemoryBytes: 1 << 20, MaxProcesses: 5}, true, "", nil},
+		{"process limit without cgroup", argv, ResourceLimits{MaxProcesses: 5}, false, "", nil},
+		{
+			"memory without cgroup", argv,
+			ResourceLimits{MemoryBytes: 1 << 20, MaxProcesses: 5}, false,
+			sh, &ResourceLimits{MemoryBytes: 1 << 20},
+		},
+		{
+			"memory left to cgroup", argv,
+			ResourceLimits{CPUTime: time.Second, MemoryBytes: 1 << 20, Nice: 5, IOClass: IOClassIdle}, true,
+			sh, &ResourceLimits{CPUTime: time.Second, Nice: 5, IOClass: IOClassIdle},
+		},
+		{
+			"path kept", []string{"/bin/sh", "-c", "true"},
+			ResourceLimits{Nice: 5}, false,
+			"/bin/sh", &ResourceLimits{Nice: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := limitedArgv(tt.argv, tt.limits, tt.cgroup)
+			if err != nil {
+				t.Fatalf("limitedArgv() = %v", err)
+			}
+			if tt.wantLimits == nil {
+				if !reflect.DeepEqual(got, tt.argv) {
+					t.Errorf("limitedArgv() = %q, want %q unchanged", got, tt.argv)
+				}
+				return
+			}
+
+			if len(got) != 4+len(tt.argv) || got[0] != self || got[1] != limitsHelperArg || got[3] != tt.wantPath || !reflect.DeepEqual(got[4:], tt.argv) {
+				t.Fatalf("limitedArgv() = %q, want %s %s <limits> %s %q", got, self, limitsHelperArg, tt.wantPath, tt.argv)
+			}
+			var limits ResourceLimits
+			if err := json.Unmarshal([]byte(got[2]), &limits); err != nil {
+				t.Fatalf("limits %q: %v", got[2], err)
+			}
+			if limits != *tt.wantLimits {
+				t.Errorf("helper limits = %+v, want %+v", limits, *tt.wantLimits)
+			}
+		})
+	}
+
+	if _, err := limitedArgv([]string{"syswatch-no-such-command"}, ResourceLimits{Nice: 5}, false); err == nil {
+		t.Error("limitedArgv() of a missing program succeeded")
+	}
+}
+
+func TestRunLimitsHelper(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits ResourceLimits
+		script string
+		want   string
+	}{
+		{"cpu time", ResourceLimits{CPUTime: 1500 * time.Millisecond}, "ulimit -t", "2\n"},
+		{"nice", ResourceLimits{Nice: 7}, "nice", "7\n"},
+		{"memory", ResourceLimits{MemoryBytes: 1 << 30}, "ulimit -v", "1048576\n"},
+		{"all", ResourceLimits{CPUTime: 5 * time.Second, MemoryBytes: 1 << 30, Nice: 3, IOClass: IOClassIdle}, "ulimit -t; ulimit -v; nice", "5\n1048576\n3\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteCommand(context.Background(), []string{"sh", "-c", tt.script}, CommandOptions{Limits: tt.limits})
+			if err != nil {
+				t.Fatalf("ExecuteCommand() = %v", err)
+			}
+			if result.Stdout != tt.want || result.ExitCode != 0 {
+				t.Errorf("stdout %q, stderr %q, exit code %d; want %q", result.Stdout, result.Stderr, result.ExitCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunLimitsHelperFailure(t *testing.T) {
+	notExecutable := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		argv     []string
+		wantExit int
+	}{
+		{"missing program", []string{"/nonexistent/syswatch"}, 127},
+		{"not executable", []string{notExecutable}, 126},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteCommand(context.Background(), tt.argv, CommandOptions{Limits: ResourceLimits{Nice: 1}})
+			if err != nil {
+				t.Fatalf("ExecuteCommand() = %v", err)
+			}
+			if result.ExitCode != tt.wantExit {
+				t.Errorf("exit code %d (%q), want %d", result.ExitCode, result.Stderr, tt.wantExit)
+			}
+		})
+	}
+}
+
+func TestProcessLimitsNeedCgroup(t *testing.T) {
+	_, err := ExecuteCommand(context.Background(), []string{"true"}, CommandOptions{Limits: ResourceLimits{MaxProcesses: 5}})
+	if err != errProcessLimitsNeedCgroup {
+		t.Errorf("ExecuteCommand() = %v, want %v", err, errProcessLimitsNeedCgroup)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !linux
+
+package utils
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errLimitsUnsupported = errors.New("resource limits are only supported on linux")
+
+// CheckCgroupParent always fails; cgroups are only supported on Linux.
+func CheckCgroupParent(dir string) error {
+	return errLimitsUnsupported
+}
+
+// resourceLimiter refuses any limits on platforms other than Linux.
+type resourceLimiter struct{}
+
+func newResourceLimiter(cmd *exec.Cmd, limits ResourceLimits, cgroupParent string) (*resourceLimiter, error) {
+	if !limits.IsZero() {
+		return nil, errLimitsUnsupported
+	}
+	return &resourceLimiter{}, nil
+}
+
+func (l *resourceLimiter) release() {}
+
+// limitedArgv returns argv unchanged; newResourceLimiter refuses any limits.
+func limitedArgv(argv []string, limits ResourceLimits, cgroup bool) ([]string, error) {
+	return argv, nil
+}
+
+// RunLimitsHelper returns straight away; resource limits are only supported
+// on Linux.
+func RunLimitsHelper() {}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResourceLimitsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits ResourceLimits
+		valid  bool
+	}{
+		{"none", ResourceLimits{}, true},
+		{"all", ResourceLimits{CPUTime: time.Minute, MemoryBytes: 1 << 30, MaxProcesses: 10, Nice: 19, IOClass: IOClassBestEffort, IOPriority: 7}, true},
+		{"idle", ResourceLimits{IOClass: IOClassIdle}, true},
+		{"negative cpu time", ResourceLimits{CPUTime: -time.Second}, false},
+		{"negative memory", ResourceLimits{MemoryBytes: -1}, false},
+		{"negative processes", ResourceLimits{MaxProcesses: -1}, false},
+		{"negative nice", ResourceLimits{Nice: -5}, false},
+		{"nice too high", ResourceLimits{Nice: 20}, false},
+		{"I/O priority too high", ResourceLimits{IOClass: IOClassBestEffort, IOPriority: 8}, false},
+		{"negative I/O priority", ResourceLimits{IOClass: IOClassBestEffort, IOPriority: -1}, false},
+		{"realtime I/O", ResourceLimits{IOClass: "realtime"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limits.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestResourceLimitsTighten(t *testing.T) {
+	agent := ResourceLimits{CPUTime: time.Minute, MemoryBytes: 1 << 30, Nice: 5, IOClass: IOClassBestEffort, IOPriority: 4}
+	tests := []struct {
+		name      string
+		limits    ResourceLimits
+		requested ResourceLimits
+		want      ResourceLimits
+	}{
+		{"nothing requested", agent, ResourceLimits{}, agent},
+		{
+			"stricter",
+			agent,
+			ResourceLimits{CPUTime: time.Second, MemoryBytes: 1 << 20, MaxProcesses: 5, Nice: 10, IOClass: IOClassIdle},
+			ResourceLimits{CPUTime: time.Second, MemoryBytes: 1 << 20, MaxProcesses: 5, Nice: 10, IOClass: IOClassIdle},
+		},
+		{
+			"looser",
+			agent,
+			ResourceLimits{CPUTime: time.Hour, MemoryBytes: 1 << 40, Nice: 1, IOClass: IOClassBestEffort, IOPriority: 0},
+			agent,
+		},
+		{
+			"lower I/O priority within best-effort",
+			agent,
+			ResourceLimits{IOClass: IOClassBestEffort, IOPriority: 6},
+			ResourceLimits{CPUTime: time.Minute, MemoryBytes: 1 << 30, Nice: 5, IOClass: IOClassBestEffort, IOPriority: 6},
+		},
+		{
+			"no agent limits",
+			ResourceLimits{},
+			ResourceLimits{CPUTime: time.Hour, MemoryBytes: 1 << 40, MaxProcesses: 100, IOClass: IOClassBestEffort, IOPriority: 0},
+			ResourceLimits{CPUTime: time.Hour, MemoryBytes: 1 << 40, MaxProcesses: 100, IOClass: IOClassBestEffort, IOPriority: 0},
+		},
+		{
+			"idle is not raised",
+			ResourceLimits{IOClass: IOClassIdle},
+			ResourceLimits{IOClass: IOClassBestEffort, IOPriority: 7},
+			ResourceLimits{IOClass: IOClassIdle},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.Tighten(tt.requested); got != tt.want {
+				t.Errorf("Tighten() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
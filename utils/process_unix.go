@@ -0,0 +1,43 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// killProcessGroup starts cmd in a process group of its own and, when its
+// context is done, terminates the whole group rather than just the shell so
+// that children such as pipelines do not outlive it. The group is sent
+// SIGTERM, then SIGKILL once grace has passed.
+//
+// The returned function must be called once Wait has returned. Once the group
+// leader has been reaped its ID can be reused, so rather than leaving the
+// SIGKILL to fire later it kills whatever is left of the group straight away.
+func killProcessGroup(cmd *exec.Cmd, grace time.Duration) func() {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	// Wait does not return until Cancel has, so timer needs no lock.
+	var timer *time.Timer
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		timer = time.AfterFunc(grace, func() { syscall.Kill(-pgid, syscall.SIGKILL) })
+		return syscall.Kill(-pgid, syscall.SIGTERM)
+	}
+
+	// Stop waiting on output shortly after the group is killed, or after the
+	// command exits, even if a process that left the group still holds the
+	// pipes open.
+	cmd.WaitDelay = grace + time.Second
+
+	return func() {
+		if timer != nil && timer.Stop() {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package utils
+
+import (
+	"os/exec"
+	"time"
+)
+
+// killProcessGroup leaves cmd to be killed on its own when its context is
+// done; Windows has no process groups to signal. Waiting on output stops
+// shortly after, even if a child process still holds the pipes open. The
+// returned function has nothing left to do once Wait has returned.
+func killProcessGroup(cmd *exec.Cmd, grace time.Duration) func() {
+	cmd.WaitDelay = grace
+	return func() {}
+}
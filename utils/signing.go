@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// signingContext prefixes every signed payload so that a command signature
+// cannot be mistaken for a signature over anything else.
+const signingContext = "syswatch-command-v1\n"
+
+// SignedCommand is what an operator signs: the command, how it runs, the agent
+// it is for and until when it may run. Every field that changes what the agent
+// executes is included.
+type SignedCommand struct {
+	CommandID string `json:"command_id"`
+	Target    string `json:"target"`     // Agent ID of the only agent that may run the command
+	ExpiresAt int64  `json:"expires_at"` // Unix seconds after which the command is refused
+
+	Command        string            `json:"command"`
+	Shell          bool              `json:"shell"`
+	Program        string            `json:"program"`
+	Arguments      []string          `json:"arguments"`
+	Template       string            `json:"template"`
+	Args           map[string]string `json:"args"`
+	Cwd            string            `json:"cwd"`
+	Env            map[string]string `json:"env"`
+	User           string            `json:"user"`
+	Timeout        time.Duration     `json:"timeout"`
+	MaxOutputBytes uint64            `json:"max_output_bytes"`
+	Limits         ResourceLimits    `json:"limits"`
+}
+
+// Payload returns the bytes that are signed. Empty lists and maps encode the
+// same as missing ones, and map keys are sorted.
+func (c SignedCommand) Payload() []byte {
+	if len(c.Arguments) == 0 {
+		c.Arguments = nil
+	}
+	if len(c.Args) == 0 {
+		c.Args = nil
+	}
+	if len(c.Env) == 0 {
+		c.Env = nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		// Nothing in SignedCommand can fail to encode.
+		panic(err)
+	}
+	return append([]byte(signingContext), data...)
+}
+
+// Sign returns the signature of c by key.
+func (c SignedCommand) Sign(key ed25519.PrivateKey) []byte {
+	return ed25519.Sign(key, c.Payload())
+}
+
+// Verify reports whether signature is key's signature of c.
+func (c SignedCommand) Verify(key ed25519.PublicKey, signature []byte) error {
+	if !ed25519.Verify(key, c.Payload(), signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// LoadPublicKey reads a PEM encoded Ed25519 public key, as written by
+// openssl pkey -pubout.
+func LoadPublicKey(filename string) (ed25519.PublicKey, error) {
+	block, err := readPEM(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 public key", filename)
+	}
+	return public, nil
+}
+
+// LoadPrivateKey reads a PEM encoded Ed25519 private key, as written by
+// openssl genpkey -algorithm ed25519.
+func LoadPrivateKey(filename string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 private key", filename)
+	}
+	return private, nil
+}
+
+func readPEM(filename string) (*pem.Block, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", filename)
+	}
+	return block, nil
+}
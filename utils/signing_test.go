@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+)
+
+func TestSignedCommandPayload(t *testing.T) {
+	base := SignedCommand{
+		CommandID: "id",
+		Target:    "agent",
+		ExpiresAt: 1700000000,
+		Command:   "uptime",
+		Timeout:   10 * time.Second,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*SignedCommand)
+		same   bool
+	}{
+		{"empty arguments", func(c *SignedCommand) { c.Arguments = []string{} }, true},
+		{"empty env", func(c *SignedCommand) { c.Env = map[string]string{} }, true},
+		{"empty args", func(c *SignedCommand) { c.Args = map[string]string{} }, true},
+		{"command", func(c *SignedCommand) { c.Command = "uptime; id" }, false},
+		{"shell", func(c *SignedCommand) { c.Shell = true }, false},
+		{"target", func(c *SignedCommand) { c.Target = "other" }, false},
+		{"expiry", func(c *SignedCommand) { c.ExpiresAt++ }, false},
+		{"env", func(c *SignedCommand) { c.Env = map[string]string{"LD_PRELOAD": "/tmp/x.so"} }, false},
+		{"cwd", func(c *SignedCommand) { c.Cwd = "/tmp" }, false},
+		{"user", func(c *SignedCommand) { c.User = "root" }, false},
+		{"timeout", func(c *SignedCommand) { c.Timeout = time.Hour }, false},
+		{"limits", func(c *SignedCommand) { c.Limits.Nice = 1 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modified := base
+			tt.modify(&modified)
+			if same := bytes.Equal(base.Payload(), modified.Payload()); same != tt.same {
+				t.Errorf("payloads equal = %v, want %v", same, tt.same)
+			}
+		})
+	}
+
+	withEnv := func() SignedCommand {
+		c := base
+		c.Env = map[string]string{"A": "1", "B": "2", "C": "3", "D": "4"}
+		return c
+	}
+	first := withEnv().Payload()
+	for i := 0; i < 20; i++ {
+		if !bytes.Equal(first, withEnv().Payload()) {
+			t.Fatal("payload depends on map iteration order")
+		}
+	}
+}
+
+func TestSignedCommandVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	command := SignedCommand{CommandID: "id", Target: "agent", ExpiresAt: 1700000000, Command: "uptime"}
+	signature := command.Sign(private)
+	if err := command.Verify(public, signature); err != nil {
+		t.Errorf("Verify() = %v, want nil", err)
+	}
+	if err := command.Verify(otherPublic, signature); err == nil {
+		t.Error("Verify() with another key succeeded")
+	}
+
+	tampered := command
+	tampered.Command = "id"
+	if err := tampered.Verify(public, signature); err == nil {
+		t.Error("Verify() of a tampered command succeeded")
+	}
+}
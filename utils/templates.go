@@ -0,0 +1,323 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Parameter types a template may declare.
+const (
+	ParamString = "string" // Matches Pattern, or the shell-safe characters when no pattern is given
+	ParamInt    = "int"    // A base 10 integer within Min and Max, not negative unless Min is
+	ParamPath   = "path"   // An absolute path of shell-safe characters without ".." elements
+	ParamEnum   = "enum"   // One of Values
+)
+
+// placeholderPattern matches a {name} placeholder in a template command.
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// shellSafePattern matches values that need no quoting in a shell command.
+var shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]+$`)
+
+// shellWordPattern matches a bare shell-safe word or a single-quoted string,
+// the two forms QuoteShell produces.
+const shellWordPattern = `([A-Za-z0-9_./:@%+,=-]+|'(?:[^']|'\\'')*')`
+
+// ParamSpec describes the values a template parameter accepts.
+type ParamSpec struct {
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Min         *int     `json:"min,omitempty"`
+	Max         *int     `json:"max,omitempty"`
+	Values      []string `json:"values,omitempty"`
+
+	pattern    *regexp.Regexp
+	allowsDash bool // Pattern itself starts with a "-", so values may be options
+}
+
+// Template is a named command with typed {placeholders}. A template either
+// has a Command run through the shell, such as "du -sh {path}", or an Argv
+// executed directly, such as ["du", "-sh", "{path}"]. A template may also name
+// the User its command runs as.
+type Template struct {
+	Description string                `json:"description,omitempty"`
+	Command     string                `json:"command,omitempty"`
+	Argv        []string              `json:"argv,omitempty"`
+	Params      map[string]*ParamSpec `json:"params,omitempty"`
+	User        string                `json:"user,omitempty"`
+
+	match     *regexp.Regexp   // Matches renderings of Command
+	argvMatch []*regexp.Regexp // Matches each rendered element of Argv
+	order     [][]string       // Parameter names in the order their placeholders appear, per pattern
+}
+
+// Compile checks that every placeholder has a parameter and every parameter a
+// placeholder, and prepares the template for Render and Match.
+func (t *Template) Compile() error {
+	if (t.Command == "") == (len(t.Argv) == 0) {
+		return fmt.Errorf("set exactly one of command or argv")
+	}
+
+	used := make(map[string]bool)
+	t.order = nil
+	if t.Command != "" {
+		match, err := t.compilePattern(t.Command, shellWordPattern, used)
+		if err != nil {
+			return err
+		}
+		t.match = match
+	} else {
+		if placeholderPattern.MatchString(t.Argv[0]) {
+			return fmt.Errorf("the program in argv must not be a placeholder")
+		}
+		t.argvMatch = nil
+		for _, element := range t.Argv {
+			match, err := t.compilePattern(element, `(.*)`, used)
+			if err != nil {
+				return err
+			}
+			t.argvMatch = append(t.argvMatch, match)
+		}
+	}
+
+	for name, param := range t.Params {
+		if param == nil {
+			return fmt.Errorf("parameter %s has no type", name)
+		}
+		if !used[name] {
+			return fmt.Errorf("parameter %s is not used in the command", name)
+		}
+		if err := param.compile(); err != nil {
+			return fmt.Errorf("parameter %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// compilePattern builds a regexp matching renderings of pattern, with each
+// placeholder matched by value, and records the placeholders it contains.
+func (t *Template) compilePattern(pattern, value string, used map[string]bool) (*regexp.Regexp, error) {
+	var names []string
+	var match strings.Builder
+	match.WriteString("^")
+	last := 0
+	for _, loc := range placeholderPattern.FindAllStringSubmatchIndex(pattern, -1) {
+		name := pattern[loc[2]:loc[3]]
+		if _, ok := t.Params[name]; !ok {
+			return nil, fmt.Errorf("placeholder {%s} has no parameter", name)
+		}
+		used[name] = true
+		names = append(names, name)
+		match.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		match.WriteString(value)
+		last = loc[1]
+	}
+	match.WriteString(regexp.QuoteMeta(pattern[last:]))
+	match.WriteString("$")
+	t.order = append(t.order, names)
+	return regexp.MustCompile(match.String()), nil
+}
+
+func (p *ParamSpec) compile() error {
+	switch p.Type {
+	case ParamString:
+		if p.Pattern != "" {
+			pattern, err := regexp.Compile("^(?:" + p.Pattern + ")$")
+			if err != nil {
+				return err
+			}
+			p.pattern = pattern
+			p.allowsDash = strings.HasPrefix(p.Pattern, "-") || strings.HasPrefix(p.Pattern, `\-`)
+		}
+	case ParamInt, ParamPath:
+	case ParamEnum:
+		if len(p.Values) == 0 {
+			return fmt.Errorf("enum has no values")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", p.Type)
+	}
+	return nil
+}
+
+// Validate reports whether value is acceptable for the parameter. A string
+// starting with "-" would be taken as an option by most commands, so it is
+// only accepted when the pattern explicitly starts with "-" too.
+func (p *ParamSpec) Validate(value string) error {
+	switch p.Type {
+	case ParamString:
+		if strings.HasPrefix(value, "-") && !p.allowsDash {
+			return fmt.Errorf("%q must not start with -", value)
+		}
+		if p.pattern != nil {
+			if !p.pattern.MatchString(value) {
+				return fmt.Errorf("%q does not match %s", value, p.Pattern)
+			}
+		} else if !shellSafePattern.MatchString(value) {
+			return fmt.Errorf("%q contains characters that are not allowed", value)
+		}
+	case ParamInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		if n < 0 && (p.Min == nil || *p.Min >= 0) {
+			return fmt.Errorf("%d must not be negative", n)
+		}
+		if p.Min != nil && n < *p.Min {
+			return fmt.Errorf("%d is below the minimum of %d", n, *p.Min)
+		}
+		if p.Max != nil && n > *p.Max {
+			return fmt.Errorf("%d is above the maximum of %d", n, *p.Max)
+		}
+	case ParamPath:
+		if !strings.HasPrefix(value, "/") || !shellSafePattern.MatchString(value) {
+			return fmt.Errorf("%q is not an absolute path of allowed characters", value)
+		}
+		for _, element := range strings.Split(value, "/") {
+			if element == ".." {
+				return fmt.Errorf("%q must not contain ..", value)
+			}
+		}
+	case ParamEnum:
+		for _, allowed := range p.Values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(p.Values, ", "))
+	default:
+		return fmt.Errorf("unknown type %q", p.Type)
+	}
+	return nil
+}
+
+// Render validates args against the template's parameters and substitutes
+// them into it. A Command template renders to a shell command with the
+// arguments shell-quoted; an Argv template renders to argv, along with its
+// shell-quoted form for display.
+func (t *Template) Render(args map[string]string) (command string, argv []string, err error) {
+	for name := range args {
+		if _, ok := t.Params[name]; !ok {
+			return "", nil, fmt.Errorf("unknown argument %s", name)
+		}
+	}
+	for _, name := range sortedParams(t.Params) {
+		value, ok := args[name]
+		if !ok {
+			return "", nil, fmt.Errorf("missing argument %s", name)
+		}
+		if err := t.Params[name].Validate(value); err != nil {
+			return "", nil, fmt.Errorf("argument %s: %w", name, err)
+		}
+	}
+
+	if t.Command != "" {
+		return placeholderPattern.ReplaceAllStringFunc(t.Command, func(placeholder string) string {
+			return QuoteShell(args[placeholder[1:len(placeholder)-1]])
+		}), nil, nil
+	}
+
+	for _, element := range t.Argv {
+		argv = append(argv, placeholderPattern.ReplaceAllStringFunc(element, func(placeholder string) string {
+			return args[placeholder[1:len(placeholder)-1]]
+		}))
+	}
+	return JoinArgv(argv), argv, nil
+}
+
+// Match reports whether command is a rendering of a Command template with
+// valid arguments, returning those arguments.
+func (t *Template) Match(command string) (map[string]string, error) {
+	if t.match == nil {
+		return nil, fmt.Errorf("not a shell command template")
+	}
+	groups := t.match.FindStringSubmatch(command)
+	if groups == nil {
+		return nil, fmt.Errorf("does not match %q", t.Command)
+	}
+
+	values := make([]string, len(groups)-1)
+	for i, group := range groups[1:] {
+		values[i] = unquoteShell(group)
+	}
+	args := make(map[string]string)
+	return args, t.collectArgs(args, t.order[0], values)
+}
+
+// MatchArgv reports whether argv is a rendering of an Argv template with
+// valid arguments, returning those arguments.
+func (t *Template) MatchArgv(argv []string) (map[string]string, error) {
+	if t.argvMatch == nil {
+		return nil, fmt.Errorf("not an argv template")
+	}
+	if len(argv) != len(t.argvMatch) {
+		return nil, fmt.Errorf("does not match %q", JoinArgv(t.Argv))
+	}
+
+	args := make(map[string]string)
+	for i, match := range t.argvMatch {
+		groups := match.FindStringSubmatch(argv[i])
+		if groups == nil {
+			return nil, fmt.Errorf("does not match %q", JoinArgv(t.Argv))
+		}
+		if err := t.collectArgs(args, t.order[i], groups[1:]); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// collectArgs validates the values matched for names and adds them to args.
+func (t *Template) collectArgs(args map[string]string, names, values []string) error {
+	for i, name := range names {
+		value := values[i]
+		if previous, ok := args[name]; ok && previous != value {
+			return fmt.Errorf("argument %s differs between placeholders", name)
+		}
+		if err := t.Params[name].Validate(value); err != nil {
+			return fmt.Errorf("argument %s: %w", name, err)
+		}
+		args[name] = value
+	}
+	return nil
+}
+
+// QuoteShell returns value as a single shell word, quoting it unless it is
+// made up of characters the shell treats literally.
+func QuoteShell(value string) string {
+	if shellSafePattern.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// JoinArgv renders argv as the equivalent shell command, for display and
+// matching against policy rules.
+func JoinArgv(argv []string) string {
+	words := make([]string, len(argv))
+	for i, arg := range argv {
+		words[i] = QuoteShell(arg)
+	}
+	return strings.Join(words, " ")
+}
+
+func unquoteShell(word string) string {
+	if strings.HasPrefix(word, "'") {
+		return strings.ReplaceAll(word[1:len(word)-1], `'\''`, "'")
+	}
+	return word
+}
+
+func sortedParams(params map[string]*ParamSpec) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
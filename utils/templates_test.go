@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func intPtr(n int) *int { return &n }
+
+func compiled(t *testing.T, template Template) *Template {
+	t.Helper()
+	if err := template.Compile(); err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	return &template
+}
+
+func TestTemplateRender(t *testing.T) {
+	diskUsage := compiled(t, Template{
+		Argv:   []string{"du", "-sh", "{path}"},
+		Params: map[string]*ParamSpec{"path": {Type: ParamPath}},
+	})
+	tailLog := compiled(t, Template{
+		Argv: []string{"tail", "-n", "{lines}", "{file}"},
+		Params: map[string]*ParamSpec{
+			"lines": {Type: ParamInt, Min: intPtr(1), Max: intPtr(1000)},
+			"file":  {Type: ParamEnum, Values: []string{"/var/log/syslog"}},
+		},
+	})
+	unit := compiled(t, Template{
+		Argv:   []string{"systemctl", "status", "--", "{unit}"},
+		Params: map[string]*ParamSpec{"unit": {Type: ParamString, Pattern: "[A-Za-z0-9@._][A-Za-z0-9@._-]*"}},
+	})
+	grep := compiled(t, Template{
+		Command: "grep -c {pattern} /var/log/auth.log",
+		Params:  map[string]*ParamSpec{"pattern": {Type: ParamString, Pattern: ".+"}},
+	})
+	option := compiled(t, Template{
+		Argv:   []string{"ls", "{flag}"},
+		Params: map[string]*ParamSpec{"flag": {Type: ParamString, Pattern: "-[a-z]+"}},
+	})
+
+	tests := []struct {
+		name        string
+		template    *Template
+		args        map[string]string
+		wantCommand string
+		wantArgv    []string
+		wantErr     bool
+	}{
+		{"path", diskUsage, map[string]string{"path": "/var/log"}, "du -sh /var/log", []string{"du", "-sh", "/var/log"}, false},
+		{"relative path", diskUsage, map[string]string{"path": "var/log"}, "", nil, true},
+		{"dot dot path", diskUsage, map[string]string{"path": "/var/../etc"}, "", nil, true},
+		{"path with space", diskUsage, map[string]string{"path": "/var/log /etc"}, "", nil, true},
+		{"missing argument", diskUsage, map[string]string{}, "", nil, true},
+		{"unknown argument", diskUsage, map[string]string{"path": "/var", "extra": "x"}, "", nil, true},
+		{"int and enum", tailLog, map[string]string{"lines": "20", "file": "/var/log/syslog"}, "tail -n 20 /var/log/syslog", []string{"tail", "-n", "20", "/var/log/syslog"}, false},
+		{"int above max", tailLog, map[string]string{"lines": "1001", "file": "/var/log/syslog"}, "", nil, true},
+		{"int not a number", tailLog, map[string]string{"lines": "1; id", "file": "/var/log/syslog"}, "", nil, true},
+		{"enum not listed", tailLog, map[string]string{"lines": "20", "file": "/etc/shadow"}, "", nil, true},
+		{"unit", unit, map[string]string{"unit": "ssh.service"}, "systemctl status -- ssh.service", []string{"systemctl", "status", "--", "ssh.service"}, false},
+		{"unit as option", unit, map[string]string{"unit": "-Hroot@attacker.example"}, "", nil, true},
+		{"shell quoted", grep, map[string]string{"pattern": "it's; rm -rf /"}, `grep -c 'it'\''s; rm -rf /' /var/log/auth.log`, nil, false},
+		{"shell option", grep, map[string]string{"pattern": "-f/etc/shadow"}, "", nil, true},
+		{"option pattern", option, map[string]string{"flag": "-la"}, "ls -la", []string{"ls", "-la"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, argv, err := tt.template.Render(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, want error %v", err, tt.wantErr)
+			}
+			if command != tt.wantCommand || !reflect.DeepEqual(argv, tt.wantArgv) {
+				t.Errorf("Render() = %q, %q, want %q, %q", command, argv, tt.wantCommand, tt.wantArgv)
+			}
+		})
+	}
+}
+
+func TestTemplateMatch(t *testing.T) {
+	topMemory := compiled(t, Template{
+		Command: "ps aux --sort=-%mem | head -n {count}",
+		Params:  map[string]*ParamSpec{"count": {Type: ParamInt, Min: intPtr(1), Max: intPtr(100)}},
+	})
+	grep := compiled(t, Template{
+		Command: "grep -c {pattern} {file}",
+		Params: map[string]*ParamSpec{
+			"pattern": {Type: ParamString, Pattern: ".+"},
+			"file":    {Type: ParamPath},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		template *Template
+		command  string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{"int", topMemory, "ps aux --sort=-%mem | head -n 10", map[string]string{"count": "10"}, false},
+		{"int out of range", topMemory, "ps aux --sort=-%mem | head -n 500", nil, true},
+		{"negative int", topMemory, "ps aux --sort=-%mem | head -n -5", nil, true},
+		{"second command", topMemory, "ps aux --sort=-%mem | head -n 10; id", nil, true},
+		{"command substitution", topMemory, "ps aux --sort=-%mem | head -n $(id)", nil, true},
+		{"quoted", grep, `grep -c 'it'\''s here' /var/log/auth.log`, map[string]string{"pattern": "it's here", "file": "/var/log/auth.log"}, false},
+		{"unquoted metacharacter", grep, "grep -c x;id /var/log/auth.log", nil, true},
+		{"leading dash", grep, "grep -c -f/etc/shadow /var/log/auth.log", nil, true},
+		{"different command", grep, "egrep -c x /var/log/auth.log", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.template.Match(tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Match(%q) error = %v, want error %v", tt.command, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(args, tt.want) {
+				t.Errorf("Match(%q) = %v, want %v", tt.command, args, tt.want)
+			}
+		})
+	}
+
+	rendered, _, err := grep.Render(map[string]string{"pattern": `a'b"c $x`, "file": "/tmp/f"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	args, err := grep.Match(rendered)
+	if err != nil || args["pattern"] != `a'b"c $x` {
+		t.Errorf("Match(Render()) = %v, %v, want the rendered arguments back", args, err)
+	}
+}
+
+func TestTemplateMatchArgv(t *testing.T) {
+	diskUsage := compiled(t, Template{
+		Argv:   []string{"du", "-sh", "{path}"},
+		Params: map[string]*ParamSpec{"path": {Type: ParamPath}},
+	})
+	journal := compiled(t, Template{
+		Argv:   []string{"journalctl", "--unit={unit}", "--lines={lines}"},
+		Params: map[string]*ParamSpec{"unit": {Type: ParamString}, "lines": {Type: ParamInt, Max: intPtr(100)}},
+	})
+
+	tests := []struct {
+		name     string
+		template *Template
+		argv     []string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{"path", diskUsage, []string{"du", "-sh", "/var/log"}, map[string]string{"path": "/var/log"}, false},
+		{"path with metacharacters", diskUsage, []string{"du", "-sh", "/var/log;id"}, nil, true},
+		{"extra argument", diskUsage, []string{"du", "-sh", "/var/log", "/etc"}, nil, true},
+		{"missing argument", diskUsage, []string{"du", "-sh"}, nil, true},
+		{"different program", diskUsage, []string{"rm", "-sh", "/var/log"}, nil, true},
+		{"embedded placeholders", journal, []string{"journalctl", "--unit=ssh", "--lines=20"}, map[string]string{"unit": "ssh", "lines": "20"}, false},
+		{"embedded negative int", journal, []string{"journalctl", "--unit=ssh", "--lines=-20"}, nil, true},
+		{"embedded option", journal, []string{"journalctl", "--unit=-x", "--lines=20"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.template.MatchArgv(tt.argv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchArgv(%q) error = %v, want error %v", tt.argv, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(args, tt.want) {
+				t.Errorf("MatchArgv(%q) = %v, want %v", tt.argv, args, tt.want)
+			}
+		})
+	}
+
+	if _, err := diskUsage.Match("du -sh /var/log"); err == nil {
+		t.Error("Match() of an argv template succeeded, want an error")
+	}
+}
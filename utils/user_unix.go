@@ -0,0 +1,72 @@
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// runAsUser sets cmd to run as username, with group or else the user's
+// primary group, and no supplementary groups. Switching from root to any other
+// user also clears every capability the agent holds.
+func runAsUser(cmd *exec.Cmd, username, group string) error {
+	credential, err := lookupCredential(username, group)
+	if err != nil {
+		return err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = credential
+	return nil
+}
+
+// CheckUnprivileged reports whether username, a name or uid, and group, a name
+// or gid that may be empty, exist and are not root's.
+func CheckUnprivileged(username, group string) error {
+	credential, err := lookupCredential(username, group)
+	if err != nil {
+		return err
+	}
+	if credential.Uid == 0 || credential.Gid == 0 {
+		return fmt.Errorf("user %s or its group is root", username)
+	}
+	return nil
+}
+
+func lookupCredential(username, group string) (*syscall.Credential, error) {
+	u, err := user.Lookup(username)
+	if _, unknown := err.(user.UnknownUserError); unknown {
+		u, err = user.LookupId(username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user %s has invalid uid %q", username, u.Uid)
+	}
+
+	gidStr := u.Gid
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if _, unknown := err.(user.UnknownGroupError); unknown {
+			g, err = user.LookupGroupId(group)
+		}
+		if err != nil {
+			return nil, err
+		}
+		gidStr = g.Gid
+	}
+	gid, err := strconv.ParseUint(gidStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("group of user %s has invalid gid %q", username, gidStr)
+	}
+
+	// An empty, rather than nil, list clears the supplementary groups.
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}, nil
+}
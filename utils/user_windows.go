@@ -0,0 +1,20 @@
+//go:build windows
+
+package utils
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errUserUnsupported = errors.New("running commands as another user is not supported on windows")
+
+// runAsUser is not supported on Windows, where commands always run as the agent's user.
+func runAsUser(cmd *exec.Cmd, username, group string) error {
+	return errUserUnsupported
+}
+
+// CheckUnprivileged always fails; commands cannot run as another user on Windows.
+func CheckUnprivileged(username, group string) error {
+	return errUserUnsupported
+}